@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/joho/godotenv"
 
 	"leads-import/database"
+	"leads-import/handlers"
 	"leads-import/routes"
+	"leads-import/services"
 )
 
 func init() {
@@ -16,14 +22,49 @@ func init() {
 	}
 }
 
+// uploadFormOverhead is the room left in the body limit for the multipart form around the file
+const uploadFormOverhead = 1 << 20
+
 func main() {
-	app := fiber.New()
+	limits := services.UploadLimitsFromEnv()
+	app := fiber.New(fiber.Config{
+		BodyLimit:    int(limits.MaxFileSize) + uploadFormOverhead,
+		ErrorHandler: handlers.ErrorHandler,
+	})
 	database.ConnectDb()
 	database.ConnectMongo()
 
 	routes.SetupRoutes(app)
 
-	if err := app.Listen(":3000"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	importService := services.GetImportService()
+	if err := importService.RecoverStuckImports(); err != nil {
+		log.Printf("Failed to recover stuck imports: %v", err)
+	}
+	importService.Queue.Start(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := app.Listen(":3000"); err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Print("shutting down")
+
+	// Draining the queue also makes POST /import reject new uploads while requests finish
+	timeout := importService.Queue.Config.DrainTimeout
+	queueDone := make(chan struct{})
+	go func() {
+		importService.Queue.Shutdown(timeout)
+		close(queueDone)
+	}()
+
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
 	}
+	<-queueDone
+	log.Print("shutdown complete")
 }
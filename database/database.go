@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
 	"os"
@@ -9,9 +11,11 @@ import (
 
 	"leads-import/models"
 
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -20,6 +24,45 @@ var (
 	once     sync.Once
 )
 
+// sqliteDriver opens SQLite databases with the amigocare schema attached
+const sqliteDriver = "sqlite3_amigocare"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{ConnectHook: attachSchema})
+}
+
+// attachSchema attaches the database file again as amigocare on every new
+// connection of the pool, so the schema-qualified tables live in the SQLite
+// file like the others.
+func attachSchema(conn *sqlite3.SQLiteConn) error {
+	_, err := conn.Exec("ATTACH DATABASE ? AS amigocare", []driver.Value{conn.GetFilename("main")})
+	return err
+}
+
+// OpenSQLite opens the SQLite database at path with the amigocare schema.
+func OpenSQLite(path string, config *gorm.Config) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.New(sqlite.Config{
+		DriverName: sqliteDriver,
+		DSN:        path + "?_busy_timeout=5000",
+	}), config)
+	if err != nil {
+		return nil, err
+	}
+
+	// The driver writes INSERT INTO the bare table name, which would insert into
+	// main instead of amigocare: qualify it like the other statements
+	if err := db.Callback().Create().Before("gorm:create").Register("amigocare:qualify_insert", qualifyInsert); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func qualifyInsert(db *gorm.DB) {
+	if db.Statement.TableExpr != nil {
+		db.Statement.AddClause(clause.Insert{Table: clause.Table{Name: clause.CurrentTable}})
+	}
+}
+
 func getPostgresDSN() string {
 	if url := os.Getenv("DATABASE_URL"); url != "" {
 		return url
@@ -45,24 +88,24 @@ func getEnv(key, fallback string) string {
 func GetDB() *gorm.DB {
 	once.Do(func() {
 		driver := getEnv("DB_DRIVER", "sqlite")
-		var dialector gorm.Dialector
+		config := &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Info),
+		}
 
+		var db *gorm.DB
+		var err error
 		switch driver {
 		case "postgres":
-			dialector = postgres.Open(getPostgresDSN())
+			db, err = gorm.Open(postgres.Open(getPostgresDSN()), config)
 		case "sqlite", "":
 			dbPath := getEnv("DB_PATH", "./data.db")
 			if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 				log.Fatal("Failed to create database directory: ", err)
 			}
-			dialector = sqlite.Open(dbPath)
+			db, err = OpenSQLite(dbPath, config)
 		default:
 			log.Fatalf("Unknown DB_DRIVER: %q (use sqlite or postgres)", driver)
 		}
-
-		db, err := gorm.Open(dialector, &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
-		})
 		if err != nil {
 			log.Fatal("Failed to connect to database: ", err)
 		}
@@ -74,16 +117,7 @@ func GetDB() *gorm.DB {
 			db.Exec("CREATE SCHEMA IF NOT EXISTS amigocare")
 		}
 
-		if err := db.AutoMigrate(
-			&models.Lead{},
-			&models.LeadImport{},
-			&models.LeadSource{},
-			&models.LeadChannel{},
-			&models.Tag{},
-			&models.ChatTag{},
-			&models.Patient{},
-			&models.MessagingAccount{},
-		); err != nil {
+		if err := Migrate(db); err != nil {
 			log.Fatal("Failed to auto-migrate: ", err)
 		}
 		log.Println("auto-migration completed")
@@ -93,6 +127,30 @@ func GetDB() *gorm.DB {
 	return instance
 }
 
+// Models returns a value of every model stored in the database
+func Models() []interface{} {
+	return []interface{}{
+		&models.Lead{},
+		&models.LeadImport{},
+		&models.LeadSource{},
+		&models.LeadChannel{},
+		&models.Tag{},
+		&models.ChatTag{},
+		&models.Patient{},
+		&models.MessagingAccount{},
+		&models.ImportJob{},
+		&models.ImportRow{},
+		&models.ImportIdempotencyKey{},
+		&models.CustomField{},
+		&models.ImportSettings{},
+	}
+}
+
+// Migrate creates or updates the tables of every model
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
+}
+
 // ConnectDb initializes the database connection
 func ConnectDb() {
 	GetDB()
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"leads-import/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func ListCustomFields(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	fields, err := services.GetImportService().ListCustomFields(companyID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": fields})
+}
+
+func CreateCustomField(c fiber.Ctx) error {
+	companyID, userID, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input services.CustomFieldInput
+	if err := json.Unmarshal(c.Body(), &input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid JSON body",
+			"details": err.Error(),
+		})
+	}
+
+	field, err := services.GetImportService().CreateCustomField(companyID, userID, input)
+	if errors.Is(err, services.ErrCustomFieldExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(field)
+}
+
+func DeleteCustomField(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	fieldID := fiber.Params[int](c, "id")
+	if fieldID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid custom field id"})
+	}
+
+	err := services.GetImportService().DeleteCustomField(companyID, fieldID)
+	if errors.Is(err, services.ErrCustomFieldNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"leads-import/services"
+	"leads-import/validation"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ErrorHandler answers the errors no handler turned into a response, like an
+// upload over the body limit, with the JSON error body of the API.
+func ErrorHandler(c fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		code = e.Code
+	}
+
+	message := err.Error()
+	if code == fiber.StatusRequestEntityTooLarge {
+		message = fmt.Sprintf("upload too large: the file must be at most %s",
+			validation.FormatBytes(services.UploadLimitsFromEnv().MaxFileSize))
+	}
+	return c.Status(code).JSON(fiber.Map{"error": message})
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"leads-import/models"
+	"leads-import/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func GetImport(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	importID := fiber.Params[int](c, "id")
+	if importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid import id"})
+	}
+
+	status, err := services.GetImportService().GetImport(companyID, importID)
+	if errors.Is(err, services.ErrImportNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+func ListImports(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	filter := services.ListImportsFilter{
+		CompanyID:      companyID,
+		Status:         models.LeadImportStatus(strings.ToUpper(c.Query("status"))),
+		AccountID:      fiber.Query[int](c, "account_id"),
+		SourceID:       fiber.Query[int](c, "source_id"),
+		CreatorID:      fiber.Query[int](c, "creator_id"),
+		Name:           c.Query("name"),
+		IncludeDeleted: fiber.Query[bool](c, "include_deleted"),
+		Cursor:         fiber.Query[int](c, "cursor"),
+		Limit:          fiber.Query[int](c, "limit"),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid status"})
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseDateQuery(c.Query("created_from"), false); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid created_from: " + err.Error()})
+	}
+	if filter.CreatedTo, err = parseDateQuery(c.Query("created_to"), true); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid created_to: " + err.Error()})
+	}
+
+	page, err := services.GetImportService().ListImports(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(page)
+}
+
+// parseDateQuery accepts RFC3339 timestamps or plain dates (YYYY-MM-DD).
+// A plain date used as an upper bound covers the whole day.
+func parseDateQuery(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("use RFC3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func CancelImport(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	importID := fiber.Params[int](c, "id")
+	if importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid import id"})
+	}
+
+	status, err := services.GetImportService().CancelImport(companyID, importID)
+	switch {
+	case errors.Is(err, services.ErrImportNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrImportNotRunning):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// A running import stays PROCESSING until its worker has stopped
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"import_id":        importID,
+		"status":           status,
+		"cancel_requested": true,
+	})
+}
+
+func DeleteImport(c fiber.Ctx) error {
+	companyID, userID, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	importID := fiber.Params[int](c, "id")
+	if importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid import id"})
+	}
+
+	result, err := services.GetImportService().RollbackImport(companyID, userID, importID)
+	switch {
+	case errors.Is(err, services.ErrImportNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrImportRunning):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func GetImportReport(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	importID := fiber.Params[int](c, "id")
+	if importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid import id"})
+	}
+
+	format := strings.ToLower(c.Query("format", "csv"))
+	if format != "csv" && format != "xlsx" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or xlsx"})
+	}
+
+	report, err := services.GetImportService().BuildImportReport(companyID, importID)
+	if errors.Is(err, services.ErrImportNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// The rows are written as they are read, so a failure past this point can
+	// only cut the download short
+	c.Attachment(fmt.Sprintf("import-%d-report.%s", importID, format))
+	return c.Status(fiber.StatusOK).SendStreamWriter(func(w *bufio.Writer) {
+		write := report.WriteCSV
+		if format == "xlsx" {
+			write = report.WriteXLSX
+		}
+		if err := write(w); err != nil {
+			log.Printf("import %d: failed to write report: %v", importID, err)
+			return
+		}
+		_ = w.Flush()
+	})
+}
+
+// importEventsPollInterval bounds how long the stream waits before re-reading the
+// import, which covers imports processed by another instance.
+const importEventsPollInterval = 2 * time.Second
+
+// GetImportEvents streams the progress of an import as Server-Sent Events: a
+// "progress" event after each processed chunk, then a "finished" event with the
+// final totals once the import is no longer PROCESSING.
+func GetImportEvents(c fiber.Ctx) error {
+	companyID, _, ok := requestUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid token: missing company_id or user_id",
+		})
+	}
+
+	if _, status, err := authorizeImport(c, companyID); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	importID := fiber.Params[int](c, "id")
+	if importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid import id"})
+	}
+
+	importService := services.GetImportService()
+	status, err := importService.GetImport(companyID, importID)
+	if errors.Is(err, services.ErrImportNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	updates, unsubscribe := importService.Tracker.Subscribe(importID)
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		ticker := time.NewTicker(importEventsPollInterval)
+		defer ticker.Stop()
+
+		lastProcessed := -1
+		for {
+			if status.Status != models.LeadImportStatusProcessing {
+				_ = writeImportEvent(w, "finished", status)
+				return
+			}
+			if status.ProcessedRows != lastProcessed {
+				if err := writeImportEvent(w, "progress", status); err != nil {
+					return
+				}
+				lastProcessed = status.ProcessedRows
+			} else {
+				// Keep the connection open and detect clients that went away
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+
+			select {
+			case <-updates:
+			case <-ticker.C:
+			}
+
+			next, err := importService.GetImport(companyID, importID)
+			if err != nil {
+				return
+			}
+			status = next
+		}
+	})
+}
+
+func writeImportEvent(w *bufio.Writer, event string, status *services.ImportStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -1,7 +1,13 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"strconv"
 	"strings"
 
@@ -12,30 +18,48 @@ import (
 	"github.com/gofiber/fiber/v3"
 )
 
-func ImportLeads(c fiber.Ctx) error {
-	// Extract company_id and user_id from JWT (set by auth middleware)
+// requestUser extracts company_id and user_id from the JWT (set by auth middleware)
+func requestUser(c fiber.Ctx) (int, int, bool) {
 	companyID, _ := c.Locals("company_id").(int)
 	userIDStr, _ := c.Locals("user_id").(string)
 	userID, _ := strconv.Atoi(userIDStr)
+	return companyID, userID, companyID != 0 && userID != 0
+}
+
+// authorizeImport checks the IMPORT_LEADS permission of the caller and returns its
+// bearer token, or the status code to reject the request with.
+func authorizeImport(c fiber.Ctx, companyID int) (string, int, error) {
+	authHeader := c.Get("Authorization")
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == "" || token == authHeader {
+		return "", fiber.StatusUnauthorized, fmt.Errorf("missing bearer token")
+	}
+
+	if err := services.CheckImportPermission(token, companyID); err != nil {
+		return "", fiber.StatusForbidden, err
+	}
+
+	return token, fiber.StatusOK, nil
+}
 
-	if companyID == 0 || userID == 0 {
+func ImportLeads(c fiber.Ctx) error {
+	companyID, userID, ok := requestUser(c)
+	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "invalid token: missing company_id or user_id",
 		})
 	}
 
-	// Extract bearer token
-	authHeader := c.Get("Authorization")
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == "" || token == authHeader {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "missing bearer token",
+	importService := services.GetImportService()
+	if importService.Queue.Draining() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "server is shutting down, retry the upload shortly",
 		})
 	}
 
-	// Check permission
-	if err := services.CheckImportPermission(token, companyID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+	token, status, err := authorizeImport(c, companyID)
+	if err != nil {
+		return c.Status(status).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -72,6 +96,9 @@ func ImportLeads(c fiber.Ctx) error {
 	if len(req.TagIDs) > 5 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "max 5 tag_ids allowed"})
 	}
+	if req.NumberTypePolicy != "" && !req.NumberTypePolicy.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "number_type_policy must be reject, warn or allow"})
+	}
 
 	// Parse file
 	fileHeader, err := c.FormFile("file")
@@ -80,6 +107,11 @@ func ImportLeads(c fiber.Ctx) error {
 			"error": "missing 'file': use form field 'file' with a CSV or Excel file",
 		})
 	}
+	if fileHeader.Size > importService.Limits.MaxFileSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("upload too large: the file must be at most %s", validation.FormatBytes(importService.Limits.MaxFileSize)),
+		})
+	}
 
 	file, err := fileHeader.Open()
 	if err != nil {
@@ -89,37 +121,179 @@ func ImportLeads(c fiber.Ctx) error {
 	}
 	defer file.Close()
 
-	rows, rowErrors, err := validation.ParseFile(file, fileHeader.Filename)
+	// A retried upload with the same Idempotency-Key gets the original import back
+	idempotencyKey := strings.TrimSpace(c.Get("Idempotency-Key"))
+	requestHash := ""
+	if idempotencyKey != "" && !req.DryRun {
+		if len(idempotencyKey) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Idempotency-Key must be at most 255 characters"})
+		}
+		requestHash, err = hashImportRequest(dataStr, file)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to read uploaded file",
+			})
+		}
+
+		importID, err := importService.FindIdempotentImport(companyID, userID, idempotencyKey, requestHash)
+		if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		if importID != 0 {
+			c.Set("Idempotent-Replayed", "true")
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"import_id": importID,
+			})
+		}
+	}
+
+	customFields, err := importService.ListCustomFields(companyID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   "file validation failed",
-			"details": err.Error(),
-		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if len(rowErrors) > 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":  "file contains invalid rows",
-			"errors": rowErrors,
-		})
+	settings, err := importService.GetImportSettings(companyID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	// Start import
-	importService := services.GetImportService()
-	importID, err := importService.StartImport(services.StartImportInput{
+	parseOptions := validation.ParseOptions{
+		ColumnMapping: req.ColumnMapping,
+		CustomFields:  customFields,
+		Sheet:         req.Sheet,
+		PhoneRegion:   importService.PhoneRegion(settings, req.AccountID),
+		MaxRows:       settings.MaxRows,
+
+		NumberTypePolicy: req.NumberTypePolicy,
+
+		MaxUnzipSize:    importService.Limits.MaxUnzipSize,
+		MaxUnzipXMLSize: importService.Limits.MaxUnzipXMLSize,
+	}
+	input := services.StartImportInput{
 		Request:   req,
-		Rows:      rows,
 		CompanyID: companyID,
 		UserID:    userID,
 		Token:     token,
-	})
+
+		IdempotencyKey: idempotencyKey,
+		RequestHash:    requestHash,
+	}
+
+	// Dry run: report what the import would do, invalid rows included
+	if req.DryRun {
+		parsed, err := validation.ParseFile(file, fileHeader.Filename, parseOptions)
+		if err != nil {
+			return c.Status(fileErrorStatus(err)).JSON(fiber.Map{
+				"error":   "file validation failed",
+				"details": err.Error(),
+			})
+		}
+		input.Header = parsed.Header
+		input.Rows = parsed.Rows
+
+		preview, err := importService.PreviewImport(c.Context(), input, len(parsed.Invalid))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(withRowWarnings(withRowErrors(fiber.Map{
+			"dry_run":        true,
+			"preview":        preview,
+			"column_mapping": parsed.ColumnMapping,
+		}, parsed.Errors), parsed.Warnings))
+	}
+
+	// The rows are validated and staged as the file is read
+	parser, err := validation.OpenFile(file, fileHeader.Filename, parseOptions)
+	if err != nil {
+		return c.Status(fileErrorStatus(err)).JSON(fiber.Map{
+			"error":   "file validation failed",
+			"details": err.Error(),
+		})
+	}
+	defer parser.Close()
+	input.Header = parser.Header
+
+	// Start import
+	importRecord, err := importService.StartImport(input, parser)
+	var rowsErr *services.InvalidRowsError
+	if errors.As(err, &rowsErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(withRowErrors(fiber.Map{
+			"error": rowsErr.Message,
+		}, rowsErr.Errors))
+	}
+	var replayErr *services.IdempotentReplayError
+	if errors.As(err, &replayErr) {
+		c.Set("Idempotent-Replayed", "true")
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"import_id": replayErr.ImportID,
+		})
+	}
+	if errors.Is(err, services.ErrIdempotencyKeyReused) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"import_id": importID,
-	})
+	return c.Status(fiber.StatusOK).JSON(withRowWarnings(fiber.Map{
+		"import_id":      importRecord.ID,
+		"total_invalid":  importRecord.TotalInvalid,
+		"column_mapping": parser.ColumnMapping,
+	}, parser.Warnings))
+}
+
+// fileErrorStatus is 413 for a file over a size limit, 400 for any other invalid file
+func fileErrorStatus(err error) int {
+	if errors.Is(err, validation.ErrFileTooLarge) {
+		return fiber.StatusRequestEntityTooLarge
+	}
+	return fiber.StatusBadRequest
+}
+
+// maxReturnedErrors caps the row errors listed in a response, the summary covers all of them
+const maxReturnedErrors = 100
+
+// withRowErrors adds the row errors of a parsed file to a response body: the first
+// maxReturnedErrors of them, their total and a summary grouped by column and message.
+func withRowErrors(body fiber.Map, rowErrors []validation.RowError) fiber.Map {
+	body["error_summary"] = validation.SummarizeErrors(rowErrors)
+	body["total_row_errors"] = len(rowErrors)
+	if len(rowErrors) > maxReturnedErrors {
+		rowErrors = rowErrors[:maxReturnedErrors]
+	}
+	body["errors"] = rowErrors
+	return body
+}
+
+// withRowWarnings adds the warnings of the valid rows of a file to a response
+// body, capped and summarized like withRowErrors.
+func withRowWarnings(body fiber.Map, warnings []validation.RowError) fiber.Map {
+	body["warning_summary"] = validation.SummarizeErrors(warnings)
+	body["total_warnings"] = len(warnings)
+	if len(warnings) > maxReturnedErrors {
+		warnings = warnings[:maxReturnedErrors]
+	}
+	body["warnings"] = warnings
+	return body
+}
+
+// hashImportRequest hashes the "data" field and the file content, then rewinds the file.
+func hashImportRequest(data string, file multipart.File) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(data))
+	hash.Write([]byte{0})
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
@@ -3,24 +3,32 @@ package testutil
 import (
 	"bytes"
 	"encoding/json"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"leads-import/database"
+	"leads-import/middlewares"
 	"leads-import/routes"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // SetupTestEnv sets up the test environment variables (SQLite)
 func SetupTestEnv(t *testing.T) {
 	t.Helper()
 	os.Setenv("DB_PATH", "test.db")
+	// Skip the IMPORT_LEADS permission check against the Amigo API
+	os.Setenv("AMIGO_API_URL", "IGNORE")
 }
 
-// CleanupTestEnv cleans up after tests
+// CleanupTestEnv removes the test database. The tests of a package share it,
+// see RunTests.
 func CleanupTestEnv(t *testing.T) {
 	t.Helper()
 	if err := os.Remove("test.db"); err != nil {
@@ -28,6 +36,16 @@ func CleanupTestEnv(t *testing.T) {
 	}
 }
 
+// RunTests runs the tests of a package from its TestMain. They share the test
+// database, which is removed once they are done.
+func RunTests(m *testing.M) int {
+	code := m.Run()
+	if err := os.Remove("test.db"); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove test database: %v", err)
+	}
+	return code
+}
+
 // SetupTestApp creates a new Fiber app for testing
 func SetupTestApp(t *testing.T) *fiber.App {
 	t.Helper()
@@ -40,10 +58,16 @@ func SetupTestApp(t *testing.T) *fiber.App {
 	return app
 }
 
-// CleanupTestApp cleans up after tests
+// CleanupTestApp empties the tables written by a test, so the next one starts
+// from a clean database
 func CleanupTestApp(t *testing.T) {
 	t.Helper()
-	CleanupTestEnv(t)
+	db := database.GetDB().Session(&gorm.Session{AllowGlobalUpdate: true})
+	for _, model := range database.Models() {
+		if err := db.Delete(model).Error; err != nil {
+			t.Fatalf("Failed to clean up test database: %v", err)
+		}
+	}
 }
 
 // MakeRequest makes an HTTP request to the test app
@@ -70,6 +94,80 @@ func MakeRequest(t *testing.T, app *fiber.App, method, path string, body interfa
 	return resp
 }
 
+// Token returns a JWT of the given company user, signed like the ones the
+// Protected middleware accepts
+func Token(t *testing.T, companyID int, userID int) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"user": map[string]interface{}{
+			"company_id": companyID,
+			"id":         userID,
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(middlewares.GetJWTSecret())
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	return token
+}
+
+// MakeAuthRequest makes an HTTP request to the test app with a bearer token
+func MakeAuthRequest(t *testing.T, app *fiber.App, method, path, token string, body interface{}) *http.Response {
+	t.Helper()
+
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to marshal request body: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := TestRequest(t, app, req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+
+	return resp
+}
+
+// NewUploadRequest builds a multipart request with the given form fields and a
+// "file" field holding content
+func NewUploadRequest(t *testing.T, path, token string, fields map[string]string, filename string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("Failed to write form field: %v", err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("Failed to write form file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close multipart body: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return req
+}
+
 // TestRequest runs app.Test with a no-timeout config. Use for custom requests (e.g. multipart).
 func TestRequest(t *testing.T, app *fiber.App, req *http.Request) (*http.Response, error) {
 	t.Helper()
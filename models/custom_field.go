@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// CustomFieldType is the type of the values of a custom field
+type CustomFieldType string
+
+const (
+	CustomFieldTypeText    CustomFieldType = "text"
+	CustomFieldTypeNumber  CustomFieldType = "number"
+	CustomFieldTypeDate    CustomFieldType = "date"
+	CustomFieldTypeBoolean CustomFieldType = "boolean"
+	CustomFieldTypeEnum    CustomFieldType = "enum"
+)
+
+// IsValid reports whether t is one of the known custom field types
+func (t CustomFieldType) IsValid() bool {
+	switch t {
+	case CustomFieldTypeText, CustomFieldTypeNumber, CustomFieldTypeDate, CustomFieldTypeBoolean, CustomFieldTypeEnum:
+		return true
+	}
+	return false
+}
+
+// CustomField is a company-defined lead field imported from an extra file column.
+// Lead values are stored in Lead.CustomFields under Name.
+type CustomField struct {
+	ID        int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	CompanyID int             `json:"company_id" gorm:"not null;uniqueIndex:idx_lead_custom_fields_company_name,priority:1,where:is_deleted = false"`
+	Name      string          `json:"name" gorm:"type:varchar(64);not null;uniqueIndex:idx_lead_custom_fields_company_name,priority:2"` // key in Lead.CustomFields, also matched against file headers
+	Label     string          `json:"label" gorm:"type:varchar(255)"`
+	Type      CustomFieldType `json:"type" gorm:"type:varchar(20);not null"`
+
+	// Validation rules
+	Required  bool     `json:"required" gorm:"default:false;not null"`   // a mapped column must have a value on every row
+	MaxLength *int     `json:"max_length"`                               // text only
+	Min       *float64 `json:"min"`                                      // number only
+	Max       *float64 `json:"max"`                                      // number only
+	Options   []string `json:"options" gorm:"type:text;serializer:json"` // enum only
+
+	IsDeleted bool      `json:"is_deleted" gorm:"default:false;not null"`
+	CreatorID int       `json:"creator_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null"`
+}
+
+func (CustomField) TableName() string {
+	return "amigocare.lead_custom_fields"
+}
+
+// CustomFieldValues holds the custom field values of a lead by field name
+type CustomFieldValues map[string]interface{}
+
+// GormDBDataType stores the values as jsonb on PostgreSQL so they can be queried
+// and indexed, and as JSON text elsewhere.
+func (CustomFieldValues) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
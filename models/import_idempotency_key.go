@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ImportIdempotencyKey remembers the import created for an Idempotency-Key so a
+// retried upload returns it instead of creating another one.
+type ImportIdempotencyKey struct {
+	ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Key         string    `json:"key" gorm:"type:varchar(255);not null;uniqueIndex:idx_lead_import_idempotency_keys_key,priority:3"`
+	CompanyID   int       `json:"company_id" gorm:"not null;uniqueIndex:idx_lead_import_idempotency_keys_key,priority:1"`
+	UserID      int       `json:"user_id" gorm:"not null;uniqueIndex:idx_lead_import_idempotency_keys_key,priority:2"`
+	RequestHash string    `json:"request_hash" gorm:"type:varchar(64);not null"`
+	ImportID    int       `json:"import_id" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+}
+
+func (ImportIdempotencyKey) TableName() string {
+	return "amigocare.lead_import_idempotency_keys"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ImportJobStatus represents the state of a queued import job
+type ImportJobStatus string
+
+const (
+	ImportJobStatusPending ImportJobStatus = "PENDING"
+	ImportJobStatusRunning ImportJobStatus = "RUNNING"
+	ImportJobStatusDone    ImportJobStatus = "DONE"
+	ImportJobStatusFailed  ImportJobStatus = "FAILED"
+)
+
+// ImportJob is the durable unit of work processing a lead import. Workers lease
+// it for a limited time and keep extending the lease while they run it, so a job
+// whose worker died is picked up again once its lease expires.
+type ImportJob struct {
+	ID          int             `json:"id" gorm:"primaryKey;autoIncrement"`
+	ImportID    int             `json:"import_id" gorm:"not null;index:idx_lead_import_jobs_import_id"`
+	Payload     string          `json:"-" gorm:"type:text;not null"`
+	Status      ImportJobStatus `json:"status" gorm:"type:varchar(20);default:PENDING;not null;index:idx_lead_import_jobs_status_run_at,priority:1"`
+	Attempts    int             `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int             `json:"max_attempts" gorm:"not null;default:3"`
+	RunAt       time.Time       `json:"run_at" gorm:"not null;index:idx_lead_import_jobs_status_run_at,priority:2"`
+	LockedBy    *string         `json:"locked_by" gorm:"type:varchar(255)"`
+	LockedUntil *time.Time      `json:"locked_until"`
+	LastError   *string         `json:"last_error" gorm:"type:text"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"not null"`
+}
+
+func (ImportJob) TableName() string {
+	return "amigocare.lead_import_jobs"
+}
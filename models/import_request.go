@@ -5,4 +5,22 @@ type ImportRequest struct {
 	AccountID int    `json:"account_id"`
 	SourceID  int    `json:"source_id"`
 	TagIDs    []int  `json:"tag_ids"`
+	DryRun    bool   `json:"dry_run"` // validate and preview the import without writing anything
+
+	// SkipInvalidRows imports the valid rows of a file with invalid ones instead of
+	// rejecting it. The invalid rows are stored on the import with their errors.
+	SkipInvalidRows bool `json:"skip_invalid_rows"`
+
+	// ColumnMapping maps file headers to lead fields (name, phone, cpf, email, tags)
+	// or company custom field names, e.g. {"Telefone": "phone"}. Unmapped columns are ignored. When empty the
+	// columns are detected from common header names, Portuguese included.
+	ColumnMapping map[string]string `json:"column_mapping"`
+
+	// Sheet of a spreadsheet file to import, by name or 1-based position. Defaults
+	// to the first sheet.
+	Sheet string `json:"sheet"`
+
+	// NumberTypePolicy handles the rows whose phone is not a mobile number, like a
+	// landline: reject, warn (the default) or allow.
+	NumberTypePolicy NumberTypePolicy `json:"number_type_policy"`
 }
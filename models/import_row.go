@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// ImportRowOutcome is the final result of processing one row of an import
+type ImportRowOutcome string
+
+const (
+	ImportRowOutcomeCreated          ImportRowOutcome = "created"
+	ImportRowOutcomeDuplicateLead    ImportRowOutcome = "duplicate_lead"
+	ImportRowOutcomeDuplicatePatient ImportRowOutcome = "duplicate_patient"
+	ImportRowOutcomeDuplicateChat    ImportRowOutcome = "duplicate_chat"
+	ImportRowOutcomeWhatsAppInvalid  ImportRowOutcome = "whatsapp_invalid"
+	ImportRowOutcomeError            ImportRowOutcome = "error"
+	ImportRowOutcomeInvalid          ImportRowOutcome = "invalid" // failed file validation, never processed
+)
+
+// IsDuplicate reports whether the row was skipped because the contact already exists
+func (o ImportRowOutcome) IsDuplicate() bool {
+	return o == ImportRowOutcomeDuplicateLead || o == ImportRowOutcomeDuplicatePatient || o == ImportRowOutcomeDuplicateChat
+}
+
+// ImportRow stages a parsed file row of an import. Outcome stays nil until the
+// row has been processed, which lets an interrupted import resume where it stopped.
+type ImportRow struct {
+	ID           int                    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ImportID     int                    `json:"import_id" gorm:"not null;index:idx_lead_import_rows_import_row,priority:1"`
+	RowNumber    int                    `json:"row_number" gorm:"not null;index:idx_lead_import_rows_import_row,priority:2"`
+	RawCells     []string               `json:"raw_cells" gorm:"type:text;serializer:json"`
+	Name         string                 `json:"name" gorm:"type:varchar(255)"`
+	Phone        string                 `json:"phone" gorm:"type:varchar(25)"`
+	CPF          string                 `json:"cpf" gorm:"type:varchar(11)"`
+	Email        string                 `json:"email" gorm:"type:varchar(255)"`
+	TagNames     []string               `json:"tag_names" gorm:"type:text;serializer:json"`
+	CustomFields map[string]interface{} `json:"custom_fields" gorm:"type:text;serializer:json"`
+	DialCode     string                 `json:"dial_code" gorm:"type:varchar(25)"`
+	CountryCode  string                 `json:"country_code" gorm:"type:varchar(25)"`
+	PhoneRegion  string                 `json:"phone_region" gorm:"type:varchar(2)"`
+	PhoneType    PhoneType              `json:"phone_type" gorm:"type:varchar(20)"`
+	Outcome      *ImportRowOutcome      `json:"outcome" gorm:"type:varchar(30)"`
+	LeadID       *int                   `json:"lead_id"`
+	ChatID       *string                `json:"chat_id"`
+	ErrorMessage *string                `json:"error_message" gorm:"type:text"`
+	CreatedAt    time.Time              `json:"created_at" gorm:"not null"`
+	UpdatedAt    time.Time              `json:"updated_at" gorm:"not null"`
+}
+
+func (ImportRow) TableName() string {
+	return "amigocare.lead_import_rows"
+}
+
+// NewImportRow stages a parsed row for an import
+func NewImportRow(importID int, row ParsedRow) ImportRow {
+	now := time.Now()
+	return ImportRow{
+		ImportID:     importID,
+		RowNumber:    row.RowNumber,
+		RawCells:     row.RawCells,
+		Name:         row.Name,
+		Phone:        row.Phone,
+		CPF:          row.CPF,
+		Email:        row.Email,
+		TagNames:     row.TagNames,
+		CustomFields: row.CustomFields,
+		DialCode:     row.DialCode,
+		CountryCode:  row.CountryCode,
+		PhoneRegion:  row.PhoneRegion,
+		PhoneType:    row.PhoneType,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// NewInvalidImportRow stores a row that failed validation on its import
+func NewInvalidImportRow(importID int, row InvalidRow) ImportRow {
+	now := time.Now()
+	outcome := ImportRowOutcomeInvalid
+	return ImportRow{
+		ImportID:     importID,
+		RowNumber:    row.RowNumber,
+		RawCells:     row.RawCells,
+		Outcome:      &outcome,
+		ErrorMessage: &row.Message,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ImportSettings overrides the import defaults of a company. Companies without a
+// row use the service defaults.
+type ImportSettings struct {
+	CompanyID int `json:"company_id" gorm:"primaryKey;autoIncrement:false"`
+	MaxRows   int `json:"max_rows" gorm:"not null;default:0"` // data rows per file, 0 for the default
+	// PhoneRegion is the region of local-format phones, like BR or PT. A messaging
+	// account can override it.
+	PhoneRegion string    `json:"phone_region" gorm:"type:varchar(2)"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"not null"`
+}
+
+func (ImportSettings) TableName() string {
+	return "amigocare.lead_import_settings"
+}
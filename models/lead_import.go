@@ -13,8 +13,18 @@ const (
 	LeadImportStatusFailed     LeadImportStatus = "FAILED"
 	LeadImportStatusProcessing LeadImportStatus = "PROCESSING"
 	LeadImportStatusFinished   LeadImportStatus = "FINISHED"
+	LeadImportStatusCancelled  LeadImportStatus = "CANCELLED"
 )
 
+// IsValid reports whether s is one of the known import statuses
+func (s LeadImportStatus) IsValid() bool {
+	switch s {
+	case LeadImportStatusFailed, LeadImportStatusProcessing, LeadImportStatusFinished, LeadImportStatusCancelled:
+		return true
+	}
+	return false
+}
+
 // LeadImport represents a bulk lead import job
 type LeadImport struct {
 	ID            int              `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -23,13 +33,18 @@ type LeadImport struct {
 	TotalCreated  int              `json:"total_created" gorm:"not null;default:0"`
 	TotalExisting int              `json:"total_existing" gorm:"not null;default:0"`
 	TotalErrors   int              `json:"total_errors" gorm:"not null;default:0"`
-	IsDeleted     bool             `json:"is_deleted" gorm:"default:false;not null"`
-	CreatorID     int              `json:"creator_id" gorm:"not null"`
-	CompanyID     int              `json:"company_id" gorm:"not null"`
-	SourceID      int              `json:"source_id" gorm:"not null"`
-	AccountID     int              `json:"account_id" gorm:"not null"`
-	CreatedAt     time.Time        `json:"created_at" gorm:"not null"`
-	UpdatedAt     time.Time        `json:"updated_at" gorm:"not null"`
+	TotalInvalid  int              `json:"total_invalid" gorm:"not null;default:0"` // rows skipped by file validation
+	FailureReason *string          `json:"failure_reason" gorm:"type:text"`
+	// CancelRequestedAt is set by a cancel request, the worker sets CANCELLED when it stops
+	CancelRequestedAt *time.Time `json:"cancel_requested_at"`
+	IsDeleted         bool       `json:"is_deleted" gorm:"default:false;not null"`
+	CreatorID         int        `json:"creator_id" gorm:"not null"`
+	CompanyID         int        `json:"company_id" gorm:"not null"`
+	SourceID          int        `json:"source_id" gorm:"not null"`
+	AccountID         int        `json:"account_id" gorm:"not null"`
+	Header            []string   `json:"header" gorm:"type:text;serializer:json"` // header row of the uploaded file
+	CreatedAt         time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt         time.Time  `json:"updated_at" gorm:"not null"`
 }
 
 // TableName overrides the table name to match the Sequelize model (amigocare schema)
@@ -4,6 +4,9 @@ type MessagingAccount struct {
 	ID        int  `json:"id" gorm:"primaryKey"`
 	CompanyID int  `json:"company_id" gorm:"not null"`
 	IsDeleted bool `json:"is_deleted" gorm:"default:false"`
+	// PhoneRegion is the region of local-format phones imported to the account,
+	// overriding the company one
+	PhoneRegion *string `json:"phone_region" gorm:"type:varchar(2)"`
 }
 
 func (MessagingAccount) TableName() string {
@@ -1,11 +1,24 @@
 package models
 
 type ParsedRow struct {
-	Name        string
-	Phone       string
-	CPF         string
-	Email       string
-	TagNames    []string
-	DialCode    string
-	CountryCode string
+	RowNumber int      // 1-indexed line in the file, header included
+	RawCells  []string // cells as read from the file
+	Name      string
+	Phone     string
+	CPF       string
+	Email     string
+	TagNames  []string
+	// CustomFields holds the typed values of the mapped custom field columns
+	CustomFields map[string]interface{}
+	DialCode     string
+	CountryCode  string
+	PhoneRegion  string // region the phone was parsed with
+	PhoneType    PhoneType
+}
+
+// InvalidRow is a file row that failed validation
+type InvalidRow struct {
+	RowNumber int
+	RawCells  []string
+	Message   string
 }
@@ -0,0 +1,36 @@
+package models
+
+// PhoneType classifies an imported phone number
+type PhoneType string
+
+const (
+	PhoneTypeMobile        PhoneType = "mobile"
+	PhoneTypeFixed         PhoneType = "fixed"
+	PhoneTypeFixedOrMobile PhoneType = "fixed_or_mobile" // regions like the US where the number can't tell
+	PhoneTypeVoIP          PhoneType = "voip"
+	PhoneTypeTollFree      PhoneType = "toll_free"
+	PhoneTypeOther         PhoneType = "other" // premium rate, pager, shared cost...
+)
+
+// IsMobile reports whether a number of this type can be a WhatsApp contact
+func (t PhoneType) IsMobile() bool {
+	return t == PhoneTypeMobile || t == PhoneTypeFixedOrMobile
+}
+
+// NumberTypePolicy says what an import does with phones that are not mobile numbers
+type NumberTypePolicy string
+
+const (
+	NumberTypePolicyReject NumberTypePolicy = "reject" // the row is invalid
+	NumberTypePolicyWarn   NumberTypePolicy = "warn"   // the row is imported with a warning
+	NumberTypePolicyAllow  NumberTypePolicy = "allow"  // the row is imported
+)
+
+// IsValid reports whether p is one of the known policies
+func (p NumberTypePolicy) IsValid() bool {
+	switch p {
+	case NumberTypePolicyReject, NumberTypePolicyWarn, NumberTypePolicyAllow:
+		return true
+	}
+	return false
+}
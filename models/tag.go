@@ -9,6 +9,7 @@ type Tag struct {
 	CompanyID   int       `json:"company_id" gorm:"not null"`
 	CreatorID   int       `json:"creator_id" gorm:"not null"`
 	DestroyerID *int      `json:"destroyer_id"`
+	ImportID    *int      `json:"import_id"` // import that created the tag, if any
 	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
 	UpdatedAt   time.Time `json:"updated_at" gorm:"not null"`
 }
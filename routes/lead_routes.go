@@ -8,4 +8,13 @@ import (
 
 func RegisterLeadRoutes(api fiber.Router) {
 	api.Post("/import", handlers.ImportLeads)
+	api.Get("/imports", handlers.ListImports)
+	api.Get("/imports/:id", handlers.GetImport)
+	api.Delete("/imports/:id", handlers.DeleteImport)
+	api.Post("/imports/:id/cancel", handlers.CancelImport)
+	api.Get("/imports/:id/report", handlers.GetImportReport)
+	api.Get("/imports/:id/events", handlers.GetImportEvents)
+	api.Get("/custom-fields", handlers.ListCustomFields)
+	api.Post("/custom-fields", handlers.CreateCustomField)
+	api.Delete("/custom-fields/:id", handlers.DeleteCustomField)
 }
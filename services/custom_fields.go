@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"leads-import/models"
+	"leads-import/validation"
+)
+
+var (
+	ErrCustomFieldNotFound = errors.New("custom field not found")
+	ErrCustomFieldExists   = errors.New("a custom field with this name already exists")
+)
+
+// CustomFieldInput is the body of a custom field definition
+type CustomFieldInput struct {
+	Name      string                 `json:"name"`
+	Label     string                 `json:"label"`
+	Type      models.CustomFieldType `json:"type"`
+	Required  bool                   `json:"required"`
+	MaxLength *int                   `json:"max_length"`
+	Min       *float64               `json:"min"`
+	Max       *float64               `json:"max"`
+	Options   []string               `json:"options"`
+}
+
+// ListCustomFields returns the custom fields of a company, oldest first.
+func (s *LeadImportService) ListCustomFields(companyID int) ([]models.CustomField, error) {
+	var fields []models.CustomField
+	if err := s.DB.Where("company_id = ? AND is_deleted = false", companyID).Order("id").Find(&fields).Error; err != nil {
+		return nil, fmt.Errorf("failed to load custom fields: %w", err)
+	}
+	return fields, nil
+}
+
+func (s *LeadImportService) CreateCustomField(companyID int, userID int, input CustomFieldInput) (*models.CustomField, error) {
+	options := make([]string, 0, len(input.Options))
+	for _, option := range input.Options {
+		options = append(options, strings.TrimSpace(option))
+	}
+
+	field := models.CustomField{
+		CompanyID: companyID,
+		Name:      strings.TrimSpace(input.Name),
+		Label:     strings.TrimSpace(input.Label),
+		Type:      models.CustomFieldType(strings.ToLower(string(input.Type))),
+		Required:  input.Required,
+		MaxLength: input.MaxLength,
+		Min:       input.Min,
+		Max:       input.Max,
+		Options:   options,
+		CreatorID: userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := validation.ValidateCustomField(field); err != nil {
+		return nil, err
+	}
+
+	if s.customFieldExists(companyID, field.Name) {
+		return nil, ErrCustomFieldExists
+	}
+
+	if err := s.DB.Create(&field).Error; err != nil {
+		// A concurrent request created it first: the unique index rejected this one
+		if s.customFieldExists(companyID, field.Name) {
+			return nil, ErrCustomFieldExists
+		}
+		return nil, fmt.Errorf("failed to create custom field: %w", err)
+	}
+	return &field, nil
+}
+
+func (s *LeadImportService) customFieldExists(companyID int, name string) bool {
+	var count int64
+	s.DB.Model(&models.CustomField{}).
+		Where("company_id = ? AND name = ? AND is_deleted = false", companyID, name).
+		Count(&count)
+	return count > 0
+}
+
+// DeleteCustomField stops importing a custom field. Values already stored on
+// leads are kept.
+func (s *LeadImportService) DeleteCustomField(companyID int, fieldID int) error {
+	result := s.DB.Model(&models.CustomField{}).
+		Where("id = ? AND company_id = ? AND is_deleted = false", fieldID, companyID).
+		Updates(map[string]interface{}{
+			"is_deleted": true,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete custom field: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrCustomFieldNotFound
+	}
+	return nil
+}
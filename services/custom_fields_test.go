@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateCustomFieldUniqueName(t *testing.T) {
+	s := newTestService(t)
+	input := CustomFieldInput{Name: "plano", Type: models.CustomFieldTypeText}
+
+	field, err := s.CreateCustomField(1, 1, input)
+	require.NoError(t, err)
+	_, err = s.CreateCustomField(1, 1, input)
+	assert.ErrorIs(t, err, ErrCustomFieldExists)
+	_, err = s.CreateCustomField(2, 1, input)
+	assert.NoError(t, err)
+
+	// The index rejects a duplicate a concurrent request could insert
+	duplicate := *field
+	duplicate.ID = 0
+	assert.ErrorContains(t, s.DB.Create(&duplicate).Error, "UNIQUE constraint failed")
+
+	// A deleted name can be used again
+	require.NoError(t, s.DeleteCustomField(1, field.ID))
+	_, err = s.CreateCustomField(1, 1, input)
+	assert.NoError(t, err)
+}
+
+func TestCustomFieldValuesDataType(t *testing.T) {
+	tests := []struct {
+		dialector gorm.Dialector
+		want      string
+	}{
+		{postgres.New(postgres.Config{}), "jsonb"},
+		{sqlite.Open(""), "text"},
+	}
+	for _, tt := range tests {
+		db := &gorm.DB{Config: &gorm.Config{Dialector: tt.dialector}}
+		assert.Equal(t, tt.want, models.CustomFieldValues{}.GormDBDataType(db, nil), tt.dialector.Name())
+	}
+
+	// Values survive the round trip through the column
+	s := newTestService(t)
+	lead := models.Lead{ContactCellphone: "11987654321", CompanyID: 1, CustomFields: models.CustomFieldValues{"plano": "Gold", "nota": 9.5}, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	require.NoError(t, s.DB.Create(&lead).Error)
+	var stored models.Lead
+	require.NoError(t, s.DB.First(&stored, lead.ID).Error)
+	assert.Equal(t, lead.CustomFields, stored.CustomFields)
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"leads-import/models"
+)
+
+var (
+	ErrImportNotRunning = errors.New("import is not processing")
+	ErrImportCancelled  = errors.New("import cancelled")
+)
+
+// CancelImport asks a running import to stop at the next row. The worker then
+// finishes it with status CANCELLED and the totals of the rows processed so far;
+// until then it stays PROCESSING. An import no worker holds, queued or waiting
+// to be retried, is finished right away. It returns the status of the import.
+func (s *LeadImportService) CancelImport(companyID int, importID int) (models.LeadImportStatus, error) {
+	var record models.LeadImport
+	if err := s.DB.Where("id = ? AND company_id = ? AND is_deleted = false", importID, companyID).First(&record).Error; err != nil {
+		return "", ErrImportNotFound
+	}
+	if record.Status != models.LeadImportStatusProcessing {
+		return "", ErrImportNotRunning
+	}
+
+	// Persist the request first so an import running on another instance sees it
+	// at its next chunk boundary, then stop it right away if it runs here.
+	result := s.DB.Table("amigocare.lead_imports").
+		Where("id = ? AND status = ?", importID, string(models.LeadImportStatusProcessing)).
+		Updates(map[string]interface{}{
+			"cancel_requested_at": time.Now(),
+			"updated_at":          time.Now(),
+		})
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to cancel import: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrImportNotRunning
+	}
+
+	s.Tracker.cancel(importID)
+
+	// A pending job would only see the request once its backoff is over. Taking
+	// it out of the queue races with lease on the job status, so either no worker
+	// ever runs it or its worker finishes the import.
+	claimed := s.DB.Model(&models.ImportJob{}).
+		Where("import_id = ? AND status = ?", importID, models.ImportJobStatusPending).
+		Updates(map[string]interface{}{
+			"status":     models.ImportJobStatusDone,
+			"updated_at": time.Now(),
+		})
+	if claimed.Error != nil {
+		return "", fmt.Errorf("failed to cancel import job: %w", claimed.Error)
+	}
+	if claimed.RowsAffected == 0 {
+		return models.LeadImportStatusProcessing, nil
+	}
+
+	created, existing, failed, err := s.countRowOutcomes(importID)
+	if err != nil {
+		return "", err
+	}
+	s.finalizeImport(importID, companyID, models.LeadImportStatusCancelled, created, existing, failed, nil)
+	return models.LeadImportStatusCancelled, nil
+}
+
+func (s *LeadImportService) isCancelRequested(importID int) bool {
+	var count int64
+	err := s.DB.Table("amigocare.lead_imports").Where("id = ? AND cancel_requested_at IS NOT NULL", importID).Count(&count).Error
+	return err == nil && count > 0
+}
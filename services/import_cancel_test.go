@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelImport(t *testing.T) {
+	s := newTestService(t)
+	record := createTestImport(t, s.DB)
+	ctx := s.Tracker.start(context.Background(), record.ID, 1, 0)
+
+	_, err := s.CancelImport(2, record.ID)
+	assert.ErrorIs(t, err, ErrImportNotFound)
+	_, err = s.CancelImport(1, record.ID+1)
+	assert.ErrorIs(t, err, ErrImportNotFound)
+	assert.False(t, s.isCancelRequested(record.ID))
+	assert.NoError(t, ctx.Err())
+
+	status, err := s.CancelImport(1, record.ID)
+	require.NoError(t, err)
+	// Requested only, the worker sets CANCELLED
+	assert.Equal(t, models.LeadImportStatusProcessing, status)
+	requested := reloadImport(t, s.DB, record.ID)
+	assert.Equal(t, models.LeadImportStatusProcessing, requested.Status)
+	assert.NotNil(t, requested.CancelRequestedAt)
+	assert.True(t, s.isCancelRequested(record.ID))
+	assert.Error(t, ctx.Err())
+
+	require.NoError(t, s.DB.Model(&record).Update("status", models.LeadImportStatusCancelled).Error)
+	_, err = s.CancelImport(1, record.ID)
+	assert.ErrorIs(t, err, ErrImportNotRunning)
+}
+
+func TestCancelImportWaitingForRetry(t *testing.T) {
+	s := newTestService(t)
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, brazilianRow("11987654321"), brazilianRow("11912345678"))
+	require.NoError(t, s.DB.Model(&models.ImportRow{}).Where("import_id = ? AND row_number = 2", record.ID).
+		Update("outcome", models.ImportRowOutcomeCreated).Error)
+	require.NoError(t, s.Queue.Enqueue(s.DB, record.ID, StartImportInput{}))
+	job, err := s.Queue.lease("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	require.NoError(t, s.Queue.retry(job, errors.New("database is down")))
+
+	// No worker would see the request before the backoff is over
+	status, err := s.CancelImport(1, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.LeadImportStatusCancelled, status)
+
+	cancelled := reloadImport(t, s.DB, record.ID)
+	assert.Equal(t, models.LeadImportStatusCancelled, cancelled.Status)
+	assert.Equal(t, 1, cancelled.TotalCreated)
+	assert.Equal(t, models.ImportJobStatusDone, reloadJob(t, s.DB, job.ID).Status)
+
+	require.NoError(t, s.DB.Model(job).Update("run_at", time.Now().Add(-time.Second)).Error)
+	next, err := s.Queue.lease("worker-1")
+	require.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestProcessImportCancelled(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, brazilianRow("11987654321"), brazilianRow("11912345678"))
+	// Requested on another instance: the worker sees it before the first row
+	require.NoError(t, s.DB.Model(&record).Update("cancel_requested_at", time.Now()).Error)
+
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	err := s.processImport(context.Background(), record.ID, input, false)
+	assert.ErrorIs(t, err, ErrImportCancelled)
+
+	assert.Equal(t, models.LeadImportStatusCancelled, reloadImport(t, s.DB, record.ID).Status)
+	assert.Equal(t, map[int]models.ImportRowOutcome{2: "", 3: ""}, rowOutcomes(t, s.DB, record.ID))
+	_, running := s.Tracker.Get(record.ID)
+	assert.False(t, running)
+}
+
+func TestProcessImportKeepsAFinalStatus(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, brazilianRow("11987654321"))
+	// Failed by recovery before this attempt started
+	require.NoError(t, s.DB.Model(&record).Update("status", models.LeadImportStatusFailed).Error)
+
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	require.NoError(t, s.processImport(context.Background(), record.ID, input, true))
+
+	assert.Equal(t, models.LeadImportStatusFailed, reloadImport(t, s.DB, record.ID).Status)
+	assert.Equal(t, map[int]models.ImportRowOutcome{2: ""}, rowOutcomes(t, s.DB, record.ID))
+	var leads int64
+	require.NoError(t, s.DB.Model(&models.Lead{}).Where("import_id = ?", record.ID).Count(&leads).Error)
+	assert.Zero(t, leads)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"leads-import/models"
+)
+
+const (
+	DefaultImportPageSize = 20
+	MaxImportPageSize     = 100
+)
+
+// ListImportsFilter narrows the import history of a company. Zero values are ignored.
+type ListImportsFilter struct {
+	CompanyID      int
+	Status         models.LeadImportStatus
+	AccountID      int
+	SourceID       int
+	CreatorID      int
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	Name           string
+	IncludeDeleted bool
+	Cursor         int
+	Limit          int
+}
+
+// ImportPage is a page of imports ordered from newest to oldest.
+// NextCursor is nil on the last page.
+type ImportPage struct {
+	Data       []models.LeadImport `json:"data"`
+	NextCursor *int                `json:"next_cursor"`
+}
+
+func (s *LeadImportService) ListImports(filter ListImportsFilter) (*ImportPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultImportPageSize
+	}
+	if limit > MaxImportPageSize {
+		limit = MaxImportPageSize
+	}
+
+	query := s.DB.Model(&models.LeadImport{}).Where("company_id = ?", filter.CompanyID)
+	if !filter.IncludeDeleted {
+		query = query.Where("is_deleted = false")
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", string(filter.Status))
+	}
+	if filter.AccountID != 0 {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.SourceID != 0 {
+		query = query.Where("source_id = ?", filter.SourceID)
+	}
+	if filter.CreatorID != 0 {
+		query = query.Where("creator_id = ?", filter.CreatorID)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if name := strings.TrimSpace(filter.Name); name != "" {
+		query = query.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(name)+"%")
+	}
+	if filter.Cursor > 0 {
+		query = query.Where("id < ?", filter.Cursor)
+	}
+
+	// Fetch one extra record to know whether there is a next page
+	var imports []models.LeadImport
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&imports).Error; err != nil {
+		return nil, fmt.Errorf("failed to list imports: %w", err)
+	}
+
+	page := &ImportPage{Data: imports}
+	if len(imports) > limit {
+		page.Data = imports[:limit]
+		next := page.Data[limit-1].ID
+		page.NextCursor = &next
+	}
+	if page.Data == nil {
+		page.Data = []models.LeadImport{}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"leads-import/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different request")
+
+// IdempotentReplayError is returned by StartImport when a concurrent upload with
+// the same Idempotency-Key created ImportID first.
+type IdempotentReplayError struct {
+	ImportID int
+}
+
+func (e *IdempotentReplayError) Error() string {
+	return fmt.Sprintf("Idempotency-Key was already used by import %d", e.ImportID)
+}
+
+// FindIdempotentImport returns the import created earlier with the same key by
+// the same user, or 0 if the key is new. A key reused with another request hash
+// returns ErrIdempotencyKeyReused.
+func (s *LeadImportService) FindIdempotentImport(companyID int, userID int, key string, requestHash string) (int, error) {
+	var stored models.ImportIdempotencyKey
+	err := s.DB.Where("company_id = ? AND user_id = ? AND key = ?", companyID, userID, key).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if stored.RequestHash != requestHash {
+		return 0, ErrIdempotencyKeyReused
+	}
+	return stored.ImportID, nil
+}
+
+func saveIdempotencyKey(tx *gorm.DB, input StartImportInput, importID int) error {
+	if input.IdempotencyKey == "" {
+		return nil
+	}
+	key := models.ImportIdempotencyKey{
+		Key:         input.IdempotencyKey,
+		CompanyID:   input.CompanyID,
+		UserID:      input.UserID,
+		RequestHash: input.RequestHash,
+		ImportID:    importID,
+		CreatedAt:   time.Now(),
+	}
+	if err := tx.Create(&key).Error; err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idempotentInput(name string, key string, hash string) StartImportInput {
+	return StartImportInput{
+		Request:        models.ImportRequest{Name: name, SourceID: 1, AccountID: 1},
+		CompanyID:      1,
+		UserID:         1,
+		IdempotencyKey: key,
+		RequestHash:    hash,
+	}
+}
+
+func TestFindIdempotentImport(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record, err := s.StartImport(idempotentInput("first", "key-1", "hash-1"), openTestCSV(t, "name,phone\nAna,11987654321\n"))
+	require.NoError(t, err)
+	// No key, nothing to remember
+	_, err = s.StartImport(idempotentInput("second", "", ""), openTestCSV(t, "name,phone\nAna,11987654321\n"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		userID  int
+		key     string
+		hash    string
+		want    int
+		wantErr error
+	}{
+		{"same request", 1, "key-1", "hash-1", record.ID, nil},
+		{"new key", 1, "key-2", "hash-1", 0, nil},
+		{"key of another user", 2, "key-1", "hash-1", 0, nil},
+		{"key reused with another request", 1, "key-1", "hash-2", 0, ErrIdempotencyKeyReused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.FindIdempotentImport(1, tt.userID, tt.key, tt.hash)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	var keys int64
+	require.NoError(t, s.DB.Model(&models.ImportIdempotencyKey{}).Count(&keys).Error)
+	assert.EqualValues(t, 1, keys)
+}
+
+// A concurrent retry passes the handler lookup before the first upload commits
+// and then loses the race on the unique key
+func TestStartImportConcurrentRetry(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record, err := s.StartImport(idempotentInput("first", "key-1", "hash-1"), openTestCSV(t, "name,phone\nAna,11987654321\n"))
+	require.NoError(t, err)
+
+	_, err = s.StartImport(idempotentInput("retry", "key-1", "hash-1"), openTestCSV(t, "name,phone\nAna,11987654321\n"))
+	var replay *IdempotentReplayError
+	require.ErrorAs(t, err, &replay)
+	assert.Equal(t, record.ID, replay.ImportID)
+
+	_, err = s.StartImport(idempotentInput("other", "key-1", "hash-2"), openTestCSV(t, "name,phone\nBia,11912345678\n"))
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// Neither retry left an import or a job behind
+	var imports, jobs int64
+	require.NoError(t, s.DB.Model(&models.LeadImport{}).Count(&imports).Error)
+	require.NoError(t, s.DB.Model(&models.ImportJob{}).Count(&jobs).Error)
+	assert.EqualValues(t, 1, imports)
+	assert.EqualValues(t, 1, jobs)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"leads-import/models"
+)
+
+// DuplicateBreakdown counts the rows skipped because their contact already exists.
+type DuplicateBreakdown struct {
+	Lead    int `json:"lead"`
+	Patient int `json:"patient"`
+	Chat    int `json:"chat"`
+}
+
+// ImportPreview is the projected result of an import, computed without writing anything.
+type ImportPreview struct {
+	TotalRows    int                `json:"total_rows"`
+	ToCreate     int                `json:"to_create"`
+	Duplicates   DuplicateBreakdown `json:"duplicates"`
+	Invalid      int                `json:"invalid"`
+	TagsToCreate []string           `json:"tags_to_create"`
+}
+
+// PreviewImport runs the same checks and duplicate lookup as an actual import.
+// invalidRows is the number of file rows that failed validation.
+func (s *LeadImportService) PreviewImport(ctx context.Context, input StartImportInput, invalidRows int) (*ImportPreview, error) {
+	if err := s.validateImport(input); err != nil {
+		return nil, err
+	}
+
+	preview := &ImportPreview{
+		TotalRows:    len(input.Rows) + invalidRows,
+		Invalid:      invalidRows,
+		TagsToCreate: []string{},
+	}
+	if len(input.Rows) == 0 {
+		return preview, nil
+	}
+
+	phones := make(map[phoneKey]bool, len(input.Rows))
+	for _, row := range input.Rows {
+		phones[phoneKey{DialCode: row.DialCode, Phone: row.Phone}] = true
+	}
+	duplicatePhones, err := s.findDuplicates(ctx, phones, input.CompanyID, input.Request.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tag names of the rows to create, by lowercase name as tags are matched
+	tagNames := make(map[string]string)
+	seen := make(map[phoneKey]bool, len(input.Rows))
+	for _, row := range input.Rows {
+		key := phoneKey{DialCode: row.DialCode, Phone: row.Phone}
+		outcome := duplicatePhones[key]
+		// A phone listed twice gets a single lead
+		if outcome == "" && seen[key] {
+			outcome = models.ImportRowOutcomeDuplicateLead
+		}
+		seen[key] = true
+
+		switch outcome {
+		case models.ImportRowOutcomeDuplicateLead:
+			preview.Duplicates.Lead++
+		case models.ImportRowOutcomeDuplicatePatient:
+			preview.Duplicates.Patient++
+		case models.ImportRowOutcomeDuplicateChat:
+			preview.Duplicates.Chat++
+		default:
+			preview.ToCreate++
+			for _, t := range row.TagNames {
+				if _, ok := tagNames[strings.ToLower(t)]; !ok {
+					tagNames[strings.ToLower(t)] = t
+				}
+			}
+		}
+	}
+
+	if len(tagNames) > 0 {
+		lowerNames := make([]string, 0, len(tagNames))
+		for lower := range tagNames {
+			lowerNames = append(lowerNames, lower)
+		}
+		var existing []string
+		s.DB.Model(&models.Tag{}).
+			Where("LOWER(name) IN ? AND company_id = ? AND is_deleted = false", lowerNames, input.CompanyID).
+			Pluck("LOWER(name)", &existing)
+		for _, lower := range existing {
+			delete(tagNames, lower)
+		}
+		for _, name := range tagNames {
+			preview.TagsToCreate = append(preview.TagsToCreate, name)
+		}
+		sort.Strings(preview.TagsToCreate)
+	}
+
+	return preview, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewImport(t *testing.T) {
+	s := newTestService(t)
+	s.Chats = &fakeChats{existing: []Chat{{ID: "chat-1", Phone: "11955554444"}}}
+	createTestAccount(t, s.DB)
+	require.NoError(t, s.DB.Create(&models.Lead{ContactCellphone: "11987654321", CompanyID: 1, AmigocareMessagingAccountID: 1}).Error)
+	require.NoError(t, s.DB.Create(&models.Patient{CompanyID: 1, ContactCellphone: "11912345678"}).Error)
+	require.NoError(t, s.DB.Create(&models.Tag{Name: "VIP", CompanyID: 1, CreatorID: 1}).Error)
+
+	duplicate := brazilianRow("11987654321")
+	duplicate.TagNames = []string{"ignored"}
+	first := brazilianRow("11933332222")
+	first.TagNames = []string{"vip", "Frio"}
+	second := brazilianRow("11922221111")
+	second.TagNames = []string{"frio", "Agosto"}
+	input := StartImportInput{
+		Request:   models.ImportRequest{Name: "planilha", SourceID: 1, AccountID: 1},
+		Rows:      []models.ParsedRow{duplicate, brazilianRow("11912345678"), brazilianRow("11955554444"), first, second},
+		CompanyID: 1,
+		UserID:    1,
+	}
+
+	preview, err := s.PreviewImport(context.Background(), input, 2)
+	require.NoError(t, err)
+	assert.Equal(t, &ImportPreview{
+		TotalRows:    7,
+		ToCreate:     2,
+		Duplicates:   DuplicateBreakdown{Lead: 1, Patient: 1, Chat: 1},
+		Invalid:      2,
+		TagsToCreate: []string{"Agosto", "Frio"},
+	}, preview)
+
+	// Nothing was written
+	var imports, tags int64
+	require.NoError(t, s.DB.Model(&models.LeadImport{}).Count(&imports).Error)
+	require.NoError(t, s.DB.Model(&models.Tag{}).Count(&tags).Error)
+	assert.Zero(t, imports)
+	assert.EqualValues(t, 1, tags)
+
+	input.Request.AccountID = 2
+	_, err = s.PreviewImport(context.Background(), input, 0)
+	assert.Error(t, err)
+}
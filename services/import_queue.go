@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"leads-import/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// errLeaseLost stops a job whose lease was taken over by another worker.
+	errLeaseLost = errors.New("import job lease lost")
+	// errShutdown stops a job at a checkpoint so it resumes after a restart.
+	errShutdown = errors.New("import interrupted by shutdown")
+)
+
+// ImportQueueConfig controls the import workers. See ImportQueueConfigFromEnv.
+type ImportQueueConfig struct {
+	Workers       int
+	LeaseDuration time.Duration
+	PollInterval  time.Duration
+	MaxAttempts   int
+	RetryBackoff  time.Duration
+	StaleAfter    time.Duration
+	DrainTimeout  time.Duration
+}
+
+// ImportQueueConfigFromEnv reads IMPORT_WORKERS, IMPORT_JOB_LEASE, IMPORT_JOB_POLL_INTERVAL,
+// IMPORT_JOB_MAX_ATTEMPTS, IMPORT_JOB_RETRY_BACKOFF, IMPORT_STALE_AFTER and
+// IMPORT_DRAIN_TIMEOUT, falling back to sensible defaults.
+func ImportQueueConfigFromEnv() ImportQueueConfig {
+	return ImportQueueConfig{
+		Workers:       envInt("IMPORT_WORKERS", 2),
+		LeaseDuration: envDuration("IMPORT_JOB_LEASE", 2*time.Minute),
+		PollInterval:  envDuration("IMPORT_JOB_POLL_INTERVAL", 2*time.Second),
+		MaxAttempts:   envInt("IMPORT_JOB_MAX_ATTEMPTS", 3),
+		RetryBackoff:  envDuration("IMPORT_JOB_RETRY_BACKOFF", 30*time.Second),
+		StaleAfter:    envDuration("IMPORT_STALE_AFTER", 15*time.Minute),
+		DrainTimeout:  envDuration("IMPORT_DRAIN_TIMEOUT", 20*time.Second),
+	}
+}
+
+// ImportJobHandler runs a leased job. finalAttempt is true when the job will not be retried.
+type ImportJobHandler func(ctx context.Context, job *models.ImportJob, finalAttempt bool) error
+
+// ImportQueue is a database-backed job queue for lead imports. It only relies on
+// plain conditional updates, so it works the same on PostgreSQL and SQLite.
+type ImportQueue struct {
+	DB      *gorm.DB
+	Config  ImportQueueConfig
+	Handler ImportJobHandler
+
+	workerID      string
+	wg            sync.WaitGroup
+	stopping      chan struct{}
+	stopOnce      sync.Once
+	cancelRunning context.CancelCauseFunc
+}
+
+func NewImportQueue(db *gorm.DB, config ImportQueueConfig, handler ImportJobHandler) *ImportQueue {
+	hostname, _ := os.Hostname()
+	return &ImportQueue{
+		DB:       db,
+		Config:   config,
+		Handler:  handler,
+		workerID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		stopping: make(chan struct{}),
+	}
+}
+
+// Enqueue stores a job for importID. Pass the transaction creating the import so
+// both are committed together.
+func (q *ImportQueue) Enqueue(tx *gorm.DB, importID int, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode import job: %w", err)
+	}
+
+	now := time.Now()
+	job := models.ImportJob{
+		ImportID:    importID,
+		Payload:     string(data),
+		Status:      models.ImportJobStatusPending,
+		MaxAttempts: q.Config.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return fmt.Errorf("failed to enqueue import job: %w", err)
+	}
+	return nil
+}
+
+// Start launches the configured number of workers. They run until ctx is done or
+// Shutdown is called.
+func (q *ImportQueue) Start(ctx context.Context) {
+	ctx, q.cancelRunning = context.WithCancelCause(ctx)
+	for i := 0; i < q.Config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, fmt.Sprintf("%s/%d", q.workerID, i))
+	}
+	log.Printf("started %d import workers", q.Config.Workers)
+}
+
+// Draining reports whether the queue is shutting down. Running imports check it
+// between chunks to stop at a checkpoint.
+func (q *ImportQueue) Draining() bool {
+	select {
+	case <-q.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops leasing jobs and lets the running ones reach their next chunk
+// boundary. Jobs still running after timeout are interrupted at the next row.
+// Either way they are put back in the queue to resume after a restart.
+func (q *ImportQueue) Shutdown(timeout time.Duration) {
+	q.stopOnce.Do(func() { close(q.stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("import workers still running after %s, interrupting them", timeout)
+		if q.cancelRunning != nil {
+			q.cancelRunning(errShutdown)
+		}
+		<-done
+	}
+}
+
+func (q *ImportQueue) work(ctx context.Context, workerID string) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before waiting for the next tick
+		for ctx.Err() == nil && !q.Draining() {
+			job, err := q.lease(workerID)
+			if err != nil {
+				log.Printf("failed to lease import job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			q.run(ctx, workerID, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lease claims the next runnable job: a pending job that is due, or a running
+// job whose worker stopped renewing its lease. It returns nil when there is none.
+func (q *ImportQueue) lease(workerID string) (*models.ImportJob, error) {
+	for {
+		now := time.Now()
+
+		var job models.ImportJob
+		err := q.DB.
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.ImportJobStatusPending, now, models.ImportJobStatusRunning, now).
+			Order("run_at").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if job.Attempts >= job.MaxAttempts {
+			// Its last worker died while running it
+			if err := q.abandon(&job, errors.New("worker stopped without finishing the job")); err != nil {
+				log.Printf("import job %d: %v", job.ID, err)
+			}
+			continue
+		}
+
+		// The attempts counter doubles as an optimistic lock between workers
+		lockedUntil := now.Add(q.Config.LeaseDuration)
+		result := q.DB.Model(&models.ImportJob{}).
+			Where("id = ? AND attempts = ? AND status = ?", job.ID, job.Attempts, job.Status).
+			Updates(map[string]interface{}{
+				"status":       models.ImportJobStatusRunning,
+				"attempts":     job.Attempts + 1,
+				"locked_by":    workerID,
+				"locked_until": lockedUntil,
+				"updated_at":   now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		job.Status = models.ImportJobStatusRunning
+		job.Attempts++
+		job.LockedBy = &workerID
+		job.LockedUntil = &lockedUntil
+		return &job, nil
+	}
+}
+
+func (q *ImportQueue) run(ctx context.Context, workerID string, job *models.ImportJob) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	go q.heartbeat(jobCtx, cancel, workerID, job.ID)
+
+	err := q.Handler(jobCtx, job, job.Attempts >= job.MaxAttempts)
+	var updateErr error
+	switch {
+	case err == nil, errors.Is(err, ErrImportCancelled):
+		updateErr = q.finish(job, models.ImportJobStatusDone, nil)
+	case errors.Is(err, errLeaseLost):
+		log.Printf("import job %d: %v", job.ID, err)
+	case errors.Is(err, errShutdown):
+		log.Printf("import job %d: %v, releasing it", job.ID, err)
+		updateErr = q.release(job)
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("import job %d failed after %d attempts: %v", job.ID, job.Attempts, err)
+		updateErr = q.abandon(job, err)
+	default:
+		log.Printf("import job %d failed (attempt %d/%d), retrying: %v", job.ID, job.Attempts, job.MaxAttempts, err)
+		updateErr = q.retry(job, err)
+	}
+	if updateErr != nil {
+		log.Printf("import job %d: %v", job.ID, updateErr)
+	}
+}
+
+// heartbeat keeps extending the lease of a running job. If another worker took
+// the job over, the job context is cancelled.
+func (q *ImportQueue) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, workerID string, jobID int) {
+	ticker := time.NewTicker(q.Config.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result := q.DB.Model(&models.ImportJob{}).
+			Where("id = ? AND locked_by = ? AND status = ?", jobID, workerID, models.ImportJobStatusRunning).
+			Updates(map[string]interface{}{
+				"locked_until": time.Now().Add(q.Config.LeaseDuration),
+				"updated_at":   time.Now(),
+			})
+		if result.Error != nil {
+			log.Printf("failed to renew lease of import job %d: %v", jobID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+func (q *ImportQueue) finish(job *models.ImportJob, status models.ImportJobStatus, jobErr error) error {
+	updates := map[string]interface{}{
+		"status":       status,
+		"locked_by":    nil,
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}
+	if jobErr != nil {
+		updates["last_error"] = jobErr.Error()
+	}
+	return q.updateLeased(job, updates)
+}
+
+// updateLeased updates a job only while the worker in job.LockedBy still holds
+// its lease. It returns errLeaseLost when another worker has taken it over.
+func (q *ImportQueue) updateLeased(job *models.ImportJob, updates map[string]interface{}) error {
+	if job.LockedBy == nil {
+		return errLeaseLost
+	}
+	result := q.DB.Model(&models.ImportJob{}).
+		Where("id = ? AND locked_by = ? AND status = ?", job.ID, *job.LockedBy, models.ImportJobStatusRunning).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update import job %d: %w", job.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// retry puts the job back in the queue with an exponential backoff.
+func (q *ImportQueue) retry(job *models.ImportJob, jobErr error) error {
+	backoff := q.Config.RetryBackoff << (job.Attempts - 1)
+	if backoff > 10*time.Minute {
+		backoff = 10 * time.Minute
+	}
+
+	return q.updateLeased(job, map[string]interface{}{
+		"status":       models.ImportJobStatusPending,
+		"run_at":       time.Now().Add(backoff),
+		"locked_by":    nil,
+		"locked_until": nil,
+		"last_error":   jobErr.Error(),
+		"updated_at":   time.Now(),
+	})
+}
+
+// release puts an interrupted job back in the queue without counting the attempt.
+func (q *ImportQueue) release(job *models.ImportJob) error {
+	return q.updateLeased(job, map[string]interface{}{
+		"status":       models.ImportJobStatusPending,
+		"attempts":     job.Attempts - 1,
+		"run_at":       time.Now(),
+		"locked_by":    nil,
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	})
+}
+
+// abandon fails a job that cannot be retried anymore, together with its import
+// if the job did not get to finalize it. A job taken over by another worker is
+// left to that worker.
+func (q *ImportQueue) abandon(job *models.ImportJob, jobErr error) error {
+	if err := q.finish(job, models.ImportJobStatusFailed, jobErr); err != nil {
+		return err
+	}
+
+	return q.DB.Table("amigocare.lead_imports").
+		Where("id = ? AND status = ?", job.ImportID, string(models.LeadImportStatusProcessing)).
+		Updates(map[string]interface{}{
+			"status":         string(models.LeadImportStatusFailed),
+			"failure_reason": jobErr.Error(),
+			"updated_at":     time.Now(),
+		}).Error
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestQueue returns a queue running handler and a job enqueued for a new import
+func newTestQueue(t *testing.T, handler ImportJobHandler) (*ImportQueue, models.LeadImport, *models.ImportJob) {
+	t.Helper()
+	db := newTestDB(t)
+	queue := NewImportQueue(db, testQueueConfig(), handler)
+	record := createTestImport(t, db)
+	require.NoError(t, queue.Enqueue(db, record.ID, map[string]int{"import_id": record.ID}))
+
+	job, err := queue.lease("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	return queue, record, job
+}
+
+func TestImportQueueLease(t *testing.T) {
+	queue, record, job := newTestQueue(t, nil)
+
+	assert.Equal(t, record.ID, job.ImportID)
+	assert.Equal(t, models.ImportJobStatusRunning, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "worker-1", *job.LockedBy)
+
+	// A live lease is not handed out twice
+	other, err := queue.lease("worker-2")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	// An expired one is taken over, as its worker died
+	require.NoError(t, queue.DB.Model(&models.ImportJob{}).Where("id = ?", job.ID).
+		Update("locked_until", time.Now().Add(-time.Second)).Error)
+	other, err = queue.lease("worker-2")
+	require.NoError(t, err)
+	require.NotNil(t, other)
+	assert.Equal(t, job.ID, other.ID)
+	assert.Equal(t, 2, other.Attempts)
+	assert.Equal(t, "worker-2", *other.LockedBy)
+}
+
+func TestImportQueueLeaseSkipsJobsNotDue(t *testing.T) {
+	queue, _, job := newTestQueue(t, nil)
+	require.NoError(t, queue.DB.Model(&models.ImportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status": models.ImportJobStatusPending,
+		"run_at": time.Now().Add(time.Hour),
+	}).Error)
+
+	next, err := queue.lease("worker-1")
+	require.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestImportQueueLeaseAbandonsExhaustedJobs(t *testing.T) {
+	queue, record, job := newTestQueue(t, nil)
+	require.NoError(t, queue.DB.Model(&models.ImportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"attempts":     job.MaxAttempts,
+		"locked_until": time.Now().Add(-time.Second),
+	}).Error)
+
+	next, err := queue.lease("worker-2")
+	require.NoError(t, err)
+	assert.Nil(t, next)
+
+	assert.Equal(t, models.ImportJobStatusFailed, reloadJob(t, queue.DB, job.ID).Status)
+	failed := reloadImport(t, queue.DB, record.ID)
+	assert.Equal(t, models.LeadImportStatusFailed, failed.Status)
+	require.NotNil(t, failed.FailureReason)
+	assert.Equal(t, "worker stopped without finishing the job", *failed.FailureReason)
+}
+
+func TestImportQueueRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		finalAttempt bool
+		jobStatus    models.ImportJobStatus
+		attempts     int
+	}{
+		{"success", nil, false, models.ImportJobStatusDone, 1},
+		{"cancelled", ErrImportCancelled, false, models.ImportJobStatusDone, 1},
+		{"failure is retried", errors.New("boom"), false, models.ImportJobStatusPending, 1},
+		{"shutdown does not count the attempt", errShutdown, false, models.ImportJobStatusPending, 0},
+		{"lost lease leaves the job to its new worker", errLeaseLost, false, models.ImportJobStatusRunning, 1},
+		{"failure of the final attempt", errors.New("boom"), true, models.ImportJobStatusFailed, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFinal bool
+			queue, _, job := newTestQueue(t, func(ctx context.Context, job *models.ImportJob, finalAttempt bool) error {
+				gotFinal = finalAttempt
+				return tt.err
+			})
+			if tt.finalAttempt {
+				job.Attempts = job.MaxAttempts
+				require.NoError(t, queue.DB.Model(job).Update("attempts", job.Attempts).Error)
+			}
+
+			queue.run(context.Background(), "worker-1", job)
+
+			assert.Equal(t, tt.finalAttempt, gotFinal)
+			stored := reloadJob(t, queue.DB, job.ID)
+			assert.Equal(t, tt.jobStatus, stored.Status)
+			assert.Equal(t, tt.attempts, stored.Attempts)
+			if tt.jobStatus == models.ImportJobStatusPending {
+				assert.Nil(t, stored.LockedBy)
+			}
+		})
+	}
+}
+
+func TestImportQueueRunAfterTakeover(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		finalAttempt bool
+	}{
+		{"success", nil, false},
+		{"failure", errors.New("boom"), false},
+		{"shutdown", errShutdown, false},
+		{"failure of the final attempt", errors.New("boom"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queue *ImportQueue
+			queue, record, job := newTestQueue(t, func(ctx context.Context, job *models.ImportJob, finalAttempt bool) error {
+				// The lease expired and another worker took the job over
+				require.NoError(t, queue.DB.Model(&models.ImportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+					"locked_by":    "worker-2",
+					"locked_until": time.Now().Add(time.Minute),
+				}).Error)
+				return tt.err
+			})
+			if tt.finalAttempt {
+				job.Attempts = job.MaxAttempts
+				require.NoError(t, queue.DB.Model(job).Update("attempts", job.Attempts).Error)
+			}
+
+			queue.run(context.Background(), "worker-1", job)
+
+			// Left to the worker that holds it now
+			stored := reloadJob(t, queue.DB, job.ID)
+			assert.Equal(t, models.ImportJobStatusRunning, stored.Status)
+			assert.Equal(t, "worker-2", *stored.LockedBy)
+			assert.Equal(t, job.Attempts, stored.Attempts)
+			assert.Nil(t, stored.LastError)
+			assert.Equal(t, models.LeadImportStatusProcessing, reloadImport(t, queue.DB, record.ID).Status)
+		})
+	}
+}
+
+func TestImportQueueRetryBackoff(t *testing.T) {
+	queue, _, job := newTestQueue(t, func(ctx context.Context, job *models.ImportJob, finalAttempt bool) error {
+		return errors.New("boom")
+	})
+
+	before := time.Now()
+	queue.run(context.Background(), "worker-1", job)
+
+	stored := reloadJob(t, queue.DB, job.ID)
+	assert.WithinDuration(t, before.Add(queue.Config.RetryBackoff), stored.RunAt, time.Second)
+	require.NotNil(t, stored.LastError)
+	assert.Equal(t, "boom", *stored.LastError)
+
+	// Not due before its backoff
+	next, err := queue.lease("worker-1")
+	require.NoError(t, err)
+	assert.Nil(t, next)
+}
+
+func TestImportQueueShutdownInterruptsRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	queue, _, job := newTestQueue(t, func(ctx context.Context, job *models.ImportJob, finalAttempt bool) error {
+		close(started)
+		<-ctx.Done()
+		return context.Cause(ctx)
+	})
+	// Put the leased job back so a worker picks it up
+	require.NoError(t, queue.release(job))
+
+	queue.Start(context.Background())
+	<-started
+	queue.Shutdown(10 * time.Millisecond)
+
+	stored := reloadJob(t, queue.DB, job.ID)
+	assert.Equal(t, models.ImportJobStatusPending, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+	assert.True(t, queue.Draining())
+}
+
+func TestImportQueueRunsImportJobs(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record, err := s.StartImport(StartImportInput{
+		Request:   models.ImportRequest{Name: "planilha", SourceID: 1, AccountID: 1},
+		CompanyID: 1,
+		UserID:    1,
+	}, openTestCSV(t, "name,phone\nAna,11987654321\n"))
+	require.NoError(t, err)
+	assert.Equal(t, models.LeadImportStatusProcessing, reloadImport(t, s.DB, record.ID).Status)
+
+	job, err := s.Queue.lease("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	s.Queue.run(context.Background(), "worker-1", job)
+
+	assert.Equal(t, models.ImportJobStatusDone, reloadJob(t, s.DB, job.ID).Status)
+	finished := reloadImport(t, s.DB, record.ID)
+	assert.Equal(t, models.LeadImportStatusFinished, finished.Status)
+	assert.Equal(t, 1, finished.TotalCreated)
+}
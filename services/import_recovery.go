@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"leads-import/models"
+
+	"gorm.io/gorm"
+)
+
+// RecoverStuckImports handles the imports left PROCESSING by a crash or a deploy:
+// imports whose last update is older than the queue StaleAfter setting and that no
+// worker holds a live lease on. Imports with staged rows left to process are
+// requeued, imports whose rows were all processed are finalized, and the others
+// are marked FAILED with a reason.
+func (s *LeadImportService) RecoverStuckImports() error {
+	now := time.Now()
+
+	var stuck []models.LeadImport
+	if err := s.DB.
+		Where("status = ? AND updated_at < ? AND is_deleted = false",
+			string(models.LeadImportStatusProcessing), now.Add(-s.Queue.Config.StaleAfter)).
+		Find(&stuck).Error; err != nil {
+		return fmt.Errorf("failed to find stuck imports: %w", err)
+	}
+
+	for _, record := range stuck {
+		if err := s.recoverImport(record, now); err != nil {
+			log.Printf("failed to recover import %d: %v", record.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *LeadImportService) recoverImport(record models.LeadImport, now time.Time) error {
+	var job models.ImportJob
+	err := s.DB.Where("import_id = ?", record.ID).First(&job).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	hasJob := err == nil
+
+	if hasJob {
+		switch {
+		case job.Status == models.ImportJobStatusRunning && job.LockedUntil != nil && job.LockedUntil.After(now):
+			// A live worker is on it
+			return nil
+		case job.Status == models.ImportJobStatusPending:
+			// Already waiting for a worker
+			return nil
+		}
+	}
+
+	var totalRows, pendingRows int64
+	if err := s.DB.Model(&models.ImportRow{}).Where("import_id = ?", record.ID).Count(&totalRows).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Model(&models.ImportRow{}).Where("import_id = ? AND outcome IS NULL", record.ID).Count(&pendingRows).Error; err != nil {
+		return err
+	}
+
+	switch {
+	case totalRows == 0 || !hasJob:
+		log.Printf("import %d: no persisted rows to resume, marking it as failed", record.ID)
+		return s.failStuckImport(record.ID, "import was interrupted before its rows were persisted: upload the file again")
+
+	case pendingRows > 0:
+		log.Printf("import %d: requeueing %d of %d rows", record.ID, pendingRows, totalRows)
+		// The interrupted attempt counts against the job retries: a job that keeps
+		// crashing the process is failed by lease once they run out
+		return s.DB.Model(&models.ImportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":       models.ImportJobStatusPending,
+			"run_at":       now,
+			"locked_by":    nil,
+			"locked_until": nil,
+			"updated_at":   now,
+		}).Error
+
+	default:
+		// Every row was processed but the final update was lost
+		log.Printf("import %d: all rows processed, finalizing it", record.ID)
+		created, existing, failed, err := s.countRowOutcomes(record.ID)
+		if err != nil {
+			return err
+		}
+		return s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table("amigocare.lead_imports").Where("id = ?", record.ID).Updates(map[string]interface{}{
+				"status":         string(models.LeadImportStatusFinished),
+				"total_created":  created,
+				"total_existing": existing,
+				"total_errors":   failed,
+				"updated_at":     now,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.ImportJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+				"status":       models.ImportJobStatusDone,
+				"locked_by":    nil,
+				"locked_until": nil,
+				"updated_at":   now,
+			}).Error
+		})
+	}
+}
+
+func (s *LeadImportService) failStuckImport(importID int, reason string) error {
+	return s.DB.Table("amigocare.lead_imports").
+		Where("id = ? AND status = ?", importID, string(models.LeadImportStatusProcessing)).
+		Updates(map[string]interface{}{
+			"status":         string(models.LeadImportStatusFailed),
+			"failure_reason": reason,
+			"updated_at":     time.Now(),
+		}).Error
+}
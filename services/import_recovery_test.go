@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverStuckImports(t *testing.T) {
+	created := models.ImportRowOutcomeCreated
+	tests := []struct {
+		name         string
+		rows         int
+		processed    int
+		lockedUntil  time.Duration // from now, for a RUNNING job
+		noJob        bool
+		importStatus models.LeadImportStatus
+		jobStatus    models.ImportJobStatus
+		attempts     int
+	}{
+		// The interrupted attempt still counts against the job retries
+		{"rows left are requeued", 2, 1, -time.Minute, false, models.LeadImportStatusProcessing, models.ImportJobStatusPending, 2},
+		{"live lease is left alone", 2, 1, time.Minute, false, models.LeadImportStatusProcessing, models.ImportJobStatusRunning, 2},
+		{"all rows processed is finalized", 2, 2, -time.Minute, false, models.LeadImportStatusFinished, models.ImportJobStatusDone, 2},
+		{"no staged rows fails", 0, 0, -time.Minute, false, models.LeadImportStatusFailed, models.ImportJobStatusRunning, 2},
+		{"no job fails", 2, 0, 0, true, models.LeadImportStatusFailed, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			record := createTestImport(t, s.DB)
+			rows := make([]models.ParsedRow, tt.rows)
+			for i := range rows {
+				rows[i] = brazilianRow(fmt.Sprintf("119876543%02d", i))
+			}
+			if tt.rows > 0 {
+				stageRows(t, s.DB, record.ID, rows...)
+			}
+			if tt.processed > 0 {
+				require.NoError(t, s.DB.Model(&models.ImportRow{}).
+					Where("import_id = ? AND row_number <= ?", record.ID, tt.processed+1).
+					Update("outcome", created).Error)
+			}
+
+			var job *models.ImportJob
+			if !tt.noJob {
+				require.NoError(t, s.Queue.Enqueue(s.DB, record.ID, map[string]int{"import_id": record.ID}))
+				var err error
+				job, err = s.Queue.lease("worker-1")
+				require.NoError(t, err)
+				require.NoError(t, s.DB.Model(job).Updates(map[string]interface{}{
+					"attempts":     2,
+					"locked_until": time.Now().Add(tt.lockedUntil),
+				}).Error)
+			}
+			require.NoError(t, s.DB.Model(&record).Update("updated_at", time.Now().Add(-time.Hour)).Error)
+
+			require.NoError(t, s.RecoverStuckImports())
+
+			recovered := reloadImport(t, s.DB, record.ID)
+			assert.Equal(t, tt.importStatus, recovered.Status)
+			if tt.importStatus == models.LeadImportStatusFinished {
+				assert.Equal(t, tt.processed, recovered.TotalCreated)
+			}
+			if tt.importStatus == models.LeadImportStatusFailed {
+				assert.NotNil(t, recovered.FailureReason)
+			}
+			if job != nil {
+				stored := reloadJob(t, s.DB, job.ID)
+				assert.Equal(t, tt.jobStatus, stored.Status)
+				assert.Equal(t, tt.attempts, stored.Attempts)
+			}
+		})
+	}
+}
+
+func TestRecoverStuckImportsSkipsRecentImports(t *testing.T) {
+	s := newTestService(t)
+	record := createTestImport(t, s.DB)
+
+	require.NoError(t, s.RecoverStuckImports())
+	assert.Equal(t, models.LeadImportStatusProcessing, reloadImport(t, s.DB, record.ID).Status)
+}
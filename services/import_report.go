@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"leads-import/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// defaultReportHeader is used for imports created before the file header was stored.
+var defaultReportHeader = []string{"name", "phone", "cpf", "email", "tags"}
+
+var outcomeReasons = map[models.ImportRowOutcome]string{
+	models.ImportRowOutcomeDuplicateLead:    "a lead with this phone already exists",
+	models.ImportRowOutcomeDuplicatePatient: "a patient with this phone already exists",
+	models.ImportRowOutcomeDuplicateChat:    "a chat with this phone already exists",
+	models.ImportRowOutcomeWhatsAppInvalid:  "phone is not registered on WhatsApp",
+}
+
+// reportPageSize is how many rows a report reads from the database at a time
+const reportPageSize = 1000
+
+// ImportReport lists every row of an import as it was in the file, followed by
+// its status, the reason it was not created and the created lead_id. The rows
+// are read a page at a time while the report is written.
+type ImportReport struct {
+	Header []string
+	// eachRow calls fn with every row of the report in order
+	eachRow func(fn func(row []string) error) error
+}
+
+func (s *LeadImportService) BuildImportReport(companyID int, importID int) (*ImportReport, error) {
+	var record models.LeadImport
+	if err := s.DB.Where("id = ? AND company_id = ?", importID, companyID).First(&record).Error; err != nil {
+		return nil, ErrImportNotFound
+	}
+
+	fileHeader := record.Header
+	if len(fileHeader) == 0 {
+		fileHeader = defaultReportHeader
+	}
+	header := append(append([]string{}, fileHeader...), "status", "reason", "lead_id")
+
+	eachRow := func(fn func(row []string) error) error {
+		lastRowNumber := 0
+		for {
+			var rows []models.ImportRow
+			if err := s.DB.Where("import_id = ? AND row_number > ?", importID, lastRowNumber).
+				Order("row_number").
+				Limit(reportPageSize).
+				Find(&rows).Error; err != nil {
+				return fmt.Errorf("failed to load import rows: %w", err)
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			lastRowNumber = rows[len(rows)-1].RowNumber
+
+			for _, row := range rows {
+				if err := fn(reportRow(row, len(fileHeader))); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return &ImportReport{Header: header, eachRow: eachRow}, nil
+}
+
+// reportRow lays out a staged row: its file cells, padded to width, then its
+// status, reason and lead_id.
+func reportRow(row models.ImportRow, width int) []string {
+	cells := make([]string, width, width+3)
+	copy(cells, row.RawCells)
+
+	status := "pending"
+	reason := ""
+	if row.Outcome != nil {
+		status = string(*row.Outcome)
+		reason = outcomeReasons[*row.Outcome]
+	}
+	if row.ErrorMessage != nil {
+		reason = *row.ErrorMessage
+	}
+	leadID := ""
+	if row.LeadID != nil {
+		leadID = strconv.Itoa(*row.LeadID)
+	}
+
+	return append(cells, status, reason, leadID)
+}
+
+// csvSignedNumber matches phones and signed numbers, which start like a formula
+// but only hold digits and separators.
+var csvSignedNumber = regexp.MustCompile(`^[+-][0-9 ().-]*$`)
+
+// neutralizeFormula prefixes a cell a spreadsheet would run as a formula with a
+// quote, so values from the uploaded file cannot run on the reader's machine.
+func neutralizeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) || csvSignedNumber.MatchString(cell) {
+		return cell
+	}
+	return "'" + cell
+}
+
+func (r *ImportReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	write := func(row []string) error {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = neutralizeFormula(cell)
+		}
+		return writer.Write(record)
+	}
+	if err := write(r.Header); err != nil {
+		return err
+	}
+	if err := r.eachRow(write); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (r *ImportReport) WriteXLSX(w io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	stream, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return fmt.Errorf("failed to create report sheet: %w", err)
+	}
+
+	rowNumber := 0
+	write := func(row []string) error {
+		// Write every cell as text so phones and CPFs keep their digits and a
+		// value starting with = is never run as a formula
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = v
+		}
+		rowNumber++
+		cell, err := excelize.CoordinatesToCellName(1, rowNumber)
+		if err != nil {
+			return err
+		}
+		if err := stream.SetRow(cell, values); err != nil {
+			return fmt.Errorf("failed to write report row: %w", err)
+		}
+		return nil
+	}
+	if err := write(r.Header); err != nil {
+		return err
+	}
+	if err := r.eachRow(write); err != nil {
+		return err
+	}
+	if err := stream.Flush(); err != nil {
+		return fmt.Errorf("failed to write report sheet: %w", err)
+	}
+
+	return f.Write(w)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+// staticReport is a report over the given rows
+func staticReport(header []string, rows ...[]string) *ImportReport {
+	return &ImportReport{Header: header, eachRow: func(fn func(row []string) error) error {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+// reportRows reads every row of a report
+func reportRows(t *testing.T, report *ImportReport) [][]string {
+	t.Helper()
+	var rows [][]string
+	require.NoError(t, report.eachRow(func(row []string) error {
+		rows = append(rows, row)
+		return nil
+	}))
+	return rows
+}
+
+func TestBuildImportReport(t *testing.T) {
+	s := newTestService(t)
+	s.WhatsApp = fakeWhatsApp{"11933332222": true}
+	createTestAccount(t, s.DB)
+	require.NoError(t, s.DB.Create(&models.Lead{ContactCellphone: "11987654321", CompanyID: 1, AmigocareMessagingAccountID: 1}).Error)
+
+	rows := []models.ParsedRow{brazilianRow("11987654321"), brazilianRow("11933332222"), brazilianRow("11922221111")}
+	for i := range rows {
+		rows[i].RawCells = []string{rows[i].Name, rows[i].Phone}
+	}
+	record := runTestImport(t, s, rows...)
+	require.NoError(t, s.DB.Model(&record).Updates(models.LeadImport{Header: []string{"nome", "celular"}}).Error)
+
+	_, err := s.BuildImportReport(2, record.ID)
+	assert.ErrorIs(t, err, ErrImportNotFound)
+
+	report, err := s.BuildImportReport(1, record.ID)
+	require.NoError(t, err)
+	var created models.ImportRow
+	require.NoError(t, s.DB.Where("import_id = ? AND row_number = 4", record.ID).First(&created).Error)
+
+	assert.Equal(t, []string{"nome", "celular", "status", "reason", "lead_id"}, report.Header)
+	assert.Equal(t, [][]string{
+		{"Lead 11987654321", "11987654321", "duplicate_lead", "a lead with this phone already exists", ""},
+		{"Lead 11933332222", "11933332222", "whatsapp_invalid", "phone is not registered on WhatsApp", ""},
+		{"Lead 11922221111", "11922221111", "created", "", strconv.Itoa(*created.LeadID)},
+	}, reportRows(t, report))
+}
+
+func TestBuildImportReportPendingRows(t *testing.T) {
+	s := newTestService(t)
+	record := createTestImport(t, s.DB)
+	row := brazilianRow("11987654321")
+	// A short row is padded to the header
+	row.RawCells = []string{"Ana"}
+	stageRows(t, s.DB, record.ID, row)
+
+	report, err := s.BuildImportReport(1, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "phone", "cpf", "email", "tags", "status", "reason", "lead_id"}, report.Header)
+	assert.Equal(t, [][]string{{"Ana", "", "", "", "", "pending", "", ""}}, reportRows(t, report))
+}
+
+func TestBuildImportReportPages(t *testing.T) {
+	s := newTestService(t)
+	record := createTestImport(t, s.DB)
+	rows := make([]models.ParsedRow, reportPageSize+1)
+	for i := range rows {
+		rows[i] = brazilianRow(fmt.Sprintf("119%08d", i))
+		rows[i].RawCells = []string{rows[i].Name, rows[i].Phone}
+	}
+	stageRows(t, s.DB, record.ID, rows...)
+
+	report, err := s.BuildImportReport(1, record.ID)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+
+	// Every row once, in file order, across the pages
+	require.Len(t, records, len(rows)+1)
+	for i, row := range rows {
+		assert.Equal(t, row.Phone, records[i+1][1])
+	}
+}
+
+func TestImportReportFormats(t *testing.T) {
+	want := [][]string{
+		{"name", "phone", "status", "reason", "lead_id"},
+		{"Ana, Maria", "011987654321", "created", "", "7"},
+		{"Bia", "11912345678", "error", "failed to create lead", ""},
+	}
+	report := staticReport(want[0], want[1:]...)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, want, records)
+
+	buf.Reset()
+	require.NoError(t, report.WriteXLSX(&buf))
+	f, err := excelize.OpenReader(&buf)
+	require.NoError(t, err)
+	defer f.Close()
+	sheetRows, err := f.GetRows(f.GetSheetName(0))
+	require.NoError(t, err)
+	// Written as text: the leading zero stays
+	assert.Equal(t, [][]string{want[0], want[1], {"Bia", "11912345678", "error", "failed to create lead"}}, sheetRows)
+	cellType, err := f.GetCellType(f.GetSheetName(0), "B2")
+	require.NoError(t, err)
+	assert.NotEqual(t, excelize.CellTypeNumber, cellType)
+}
+
+func TestImportReportNeutralizesFormulas(t *testing.T) {
+	report := staticReport([]string{"name", "phone", "status", "reason", "lead_id"},
+		[]string{"=HYPERLINK(\"http://x\")", "+55 (11) 98765-4321", "error", "@SUM(A1)", ""},
+		[]string{"+cmd|' /C calc'!A0", "-12.5", "error", "-1+A1", ""},
+		[]string{"\tAna", "\r11912345678", "created", "", "7"},
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		report.Header,
+		// Phones and numbers stay readable
+		{"'=HYPERLINK(\"http://x\")", "+55 (11) 98765-4321", "error", "'@SUM(A1)", ""},
+		{"'+cmd|' /C calc'!A0", "-12.5", "error", "'-1+A1", ""},
+		{"'\tAna", "'\r11912345678", "created", "", "7"},
+	}, records)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"leads-import/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrImportRunning = errors.New("import is still processing: cancel it first")
+
+// RollbackResult summarizes what was undone by RollbackImport.
+type RollbackResult struct {
+	DeletedLeads     int64 `json:"deleted_leads"`
+	DeletedChatTags  int64 `json:"deleted_chat_tags"`
+	DeletedTags      int64 `json:"deleted_tags"`
+	DeletedChats     int   `json:"deleted_chats"`
+	SkippedConverted int64 `json:"skipped_converted"`
+}
+
+// RollbackImport deletes an import together with the leads, chats and chat_tags
+// it created, and the tags nothing else uses anymore. Leads already converted
+// to patients are kept, as are their chats. The chats of rows that failed after
+// their chat was created are deleted too.
+func (s *LeadImportService) RollbackImport(companyID int, userID int, importID int) (*RollbackResult, error) {
+	var record models.LeadImport
+	if err := s.DB.Where("id = ? AND company_id = ? AND is_deleted = false", importID, companyID).First(&record).Error; err != nil {
+		return nil, ErrImportNotFound
+	}
+	if record.Status == models.LeadImportStatusProcessing {
+		return nil, ErrImportRunning
+	}
+	// A worker may still be writing rows of an import it no longer owns
+	var runningJobs int64
+	if err := s.DB.Model(&models.ImportJob{}).
+		Where("import_id = ? AND status = ?", importID, string(models.ImportJobStatusRunning)).
+		Count(&runningJobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to check import jobs: %w", err)
+	}
+	if runningJobs > 0 {
+		return nil, ErrImportRunning
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	result := &RollbackResult{}
+
+	var chatIDs []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		importLeads := tx.Model(&models.Lead{}).
+			Where("import_id = ? AND company_id = ? AND is_deleted = false AND converted_at IS NULL", importID, companyID)
+
+		if err := importLeads.Session(&gorm.Session{}).
+			Where("chat_id IS NOT NULL").
+			Pluck("chat_id", &chatIDs).Error; err != nil {
+			return fmt.Errorf("failed to load import chats: %w", err)
+		}
+		// Chats created for rows whose lead could not be saved
+		var rowChatIDs []string
+		if err := tx.Model(&models.ImportRow{}).
+			Where("import_id = ? AND chat_id IS NOT NULL AND lead_id IS NULL", importID).
+			Pluck("chat_id", &rowChatIDs).Error; err != nil {
+			return fmt.Errorf("failed to load import row chats: %w", err)
+		}
+		chatIDs = append(chatIDs, rowChatIDs...)
+
+		leadIDs := importLeads.Session(&gorm.Session{}).Select("id")
+		chatTags := tx.Table("amigocare.chat_tags").
+			Where("lead_id IN (?) AND is_deleted = false", leadIDs).
+			Updates(map[string]interface{}{
+				"is_deleted":   true,
+				"destroyer_id": userID,
+				"updated_at":   now,
+			})
+		if chatTags.Error != nil {
+			return fmt.Errorf("failed to delete chat_tags: %w", chatTags.Error)
+		}
+		result.DeletedChatTags = chatTags.RowsAffected
+
+		leads := importLeads.Session(&gorm.Session{}).Updates(map[string]interface{}{
+			"is_deleted": true,
+			"updated_at": now,
+		})
+		if leads.Error != nil {
+			return fmt.Errorf("failed to delete leads: %w", leads.Error)
+		}
+		result.DeletedLeads = leads.RowsAffected
+
+		if err := tx.Model(&models.Lead{}).
+			Where("import_id = ? AND company_id = ? AND is_deleted = false AND converted_at IS NOT NULL", importID, companyID).
+			Count(&result.SkippedConverted).Error; err != nil {
+			return fmt.Errorf("failed to count converted leads: %w", err)
+		}
+
+		// Tags created by this import that are not linked to anything else anymore
+		tags := tx.Table("amigocare.tags").
+			Where("import_id = ? AND company_id = ? AND is_deleted = false", importID, companyID).
+			Where("NOT EXISTS (SELECT 1 FROM amigocare.chat_tags ct WHERE ct.tag_id = amigocare.tags.id AND ct.is_deleted = false)").
+			Updates(map[string]interface{}{
+				"is_deleted":   true,
+				"destroyer_id": userID,
+				"updated_at":   now,
+			})
+		if tags.Error != nil {
+			return fmt.Errorf("failed to delete tags: %w", tags.Error)
+		}
+		result.DeletedTags = tags.RowsAffected
+
+		if err := tx.Table("amigocare.lead_imports").Where("id = ?", importID).Updates(map[string]interface{}{
+			"is_deleted": true,
+			"updated_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to delete import: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// MongoDB is not part of the transaction: the chats are deleted once the
+	// leads are gone for good. Deleting a chat twice is harmless.
+	if err := s.Chats.DeleteChats(ctx, chatIDs); err != nil {
+		return nil, fmt.Errorf("import deleted, but failed to delete its chats: %w", err)
+	}
+	result.DeletedChats = len(chatIDs)
+
+	_ = s.Cache.ClearLeadCache(ctx, companyID)
+	_ = s.Events.Emit(ctx, "lead:import-deleted", map[string]interface{}{
+		"import_id":  importID,
+		"company_id": companyID,
+	})
+
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackImport(t *testing.T) {
+	s := newTestService(t)
+	chats := &fakeChats{}
+	s.Chats = chats
+	createTestAccount(t, s.DB)
+	record := createTestImport(t, s.DB)
+
+	first := brazilianRow("11987654321")
+	first.TagNames = []string{"vip", "frio"}
+	second := brazilianRow("11912345678")
+	second.TagNames = []string{"vip"}
+	stageRows(t, s.DB, record.ID, first, second)
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	require.NoError(t, s.processImport(context.Background(), record.ID, input, true))
+	require.Equal(t, models.LeadImportStatusFinished, reloadImport(t, s.DB, record.ID).Status)
+
+	// The second lead became a patient: it stays, with its chat and tags
+	require.NoError(t, s.DB.Model(&models.Lead{}).Where("contact_cellphone = ?", second.Phone).Update("converted_at", time.Now()).Error)
+	// A row whose chat was created but not its lead
+	orphan, failed := "chat-orphan", models.ImportRowOutcomeError
+	require.NoError(t, s.DB.Create(&models.ImportRow{ImportID: record.ID, RowNumber: 4, Outcome: &failed, ChatID: &orphan}).Error)
+
+	_, err := s.RollbackImport(2, 1, record.ID)
+	assert.ErrorIs(t, err, ErrImportNotFound)
+
+	result, err := s.RollbackImport(1, 1, record.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &RollbackResult{DeletedLeads: 1, DeletedChatTags: 2, DeletedTags: 1, DeletedChats: 2, SkippedConverted: 1}, result)
+	assert.Len(t, chats.deleted, 2)
+	assert.Contains(t, chats.deleted, orphan)
+
+	var leads []models.Lead
+	require.NoError(t, s.DB.Where("import_id = ? AND is_deleted = false", record.ID).Find(&leads).Error)
+	require.Len(t, leads, 1)
+	assert.Equal(t, second.Phone, leads[0].ContactCellphone)
+	var tags []string
+	require.NoError(t, s.DB.Model(&models.Tag{}).Where("is_deleted = false").Pluck("name", &tags).Error)
+	assert.Equal(t, []string{"vip"}, tags)
+	assert.True(t, reloadImport(t, s.DB, record.ID).IsDeleted)
+
+	_, err = s.RollbackImport(1, 1, record.ID)
+	assert.ErrorIs(t, err, ErrImportNotFound)
+}
+
+func TestRollbackImportRunning(t *testing.T) {
+	s := newTestService(t)
+	record := createTestImport(t, s.DB)
+
+	_, err := s.RollbackImport(1, 1, record.ID)
+	assert.ErrorIs(t, err, ErrImportRunning)
+	assert.False(t, reloadImport(t, s.DB, record.ID).IsDeleted)
+}
+
+func TestRollbackImportChatsAfterCommit(t *testing.T) {
+	s := newTestService(t)
+	s.Chats = &fakeChats{deleteErr: errors.New("mongo is down")}
+	createTestAccount(t, s.DB)
+	record := runTestImport(t, s, brazilianRow("11987654321"))
+
+	_, err := s.RollbackImport(1, 1, record.ID)
+	assert.ErrorContains(t, err, "failed to delete its chats")
+	// MongoDB does not roll the leads back
+	assert.True(t, reloadImport(t, s.DB, record.ID).IsDeleted)
+	var leads int64
+	require.NoError(t, s.DB.Model(&models.Lead{}).Where("import_id = ? AND is_deleted = false", record.ID).Count(&leads).Error)
+	assert.Zero(t, leads)
+}
+
+func TestRollbackImportWithRunningJob(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	row := brazilianRow("11987654321")
+	row.TagNames = []string{"vip"}
+	record := runTestImport(t, s, row)
+	require.NoError(t, s.Queue.Enqueue(s.DB, record.ID, StartImportInput{}))
+	job, err := s.Queue.lease("worker-1")
+	require.NoError(t, err)
+	require.NotNil(t, job)
+
+	// A worker still holds the job
+	_, err = s.RollbackImport(1, 1, record.ID)
+	assert.ErrorIs(t, err, ErrImportRunning)
+	assert.False(t, reloadImport(t, s.DB, record.ID).IsDeleted)
+	assertImportDeleted(t, s, record.ID, false)
+
+	require.NoError(t, s.DB.Model(job).Update("status", models.ImportJobStatusDone).Error)
+	_, err = s.RollbackImport(1, 1, record.ID)
+	require.NoError(t, err)
+	assert.True(t, reloadImport(t, s.DB, record.ID).IsDeleted)
+	assertImportDeleted(t, s, record.ID, true)
+}
+
+// assertImportDeleted checks whether the leads, chat_tags and tags of an import
+// are all soft-deleted, or all still there
+func assertImportDeleted(t *testing.T, s *LeadImportService, importID int, deleted bool) {
+	t.Helper()
+	var leads []models.Lead
+	require.NoError(t, s.DB.Where("import_id = ?", importID).Find(&leads).Error)
+	require.Len(t, leads, 1)
+	assert.Equal(t, deleted, leads[0].IsDeleted)
+
+	var chatTags []models.ChatTag
+	require.NoError(t, s.DB.Where("lead_id = ?", leads[0].ID).Find(&chatTags).Error)
+	require.NotEmpty(t, chatTags)
+	for _, chatTag := range chatTags {
+		assert.Equal(t, deleted, chatTag.IsDeleted)
+	}
+
+	var tags []models.Tag
+	require.NoError(t, s.DB.Where("import_id = ?", importID).Find(&tags).Error)
+	require.NotEmpty(t, tags)
+	for _, tag := range tags {
+		assert.Equal(t, deleted, tag.IsDeleted)
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"leads-import/models"
+	"leads-import/validation"
+
+	"gorm.io/gorm"
+)
+
+// GetImportSettings returns the import settings of a company, with the defaults
+// filled in. The row limit defaults to IMPORT_MAX_ROWS and the phone region to
+// IMPORT_DEFAULT_PHONE_REGION.
+func (s *LeadImportService) GetImportSettings(companyID int) (models.ImportSettings, error) {
+	settings := models.ImportSettings{CompanyID: companyID}
+	err := s.DB.Where("company_id = ?", companyID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return settings, fmt.Errorf("failed to load import settings: %w", err)
+	}
+
+	if settings.MaxRows <= 0 {
+		settings.MaxRows = envInt("IMPORT_MAX_ROWS", validation.DefaultMaxRows)
+	}
+	if region, ok := validation.NormalizeRegion(settings.PhoneRegion); ok {
+		settings.PhoneRegion = region
+	} else if region, ok := validation.NormalizeRegion(os.Getenv("IMPORT_DEFAULT_PHONE_REGION")); ok {
+		settings.PhoneRegion = region
+	} else {
+		settings.PhoneRegion = validation.DefaultPhoneRegion
+	}
+	return settings, nil
+}
+
+// PhoneRegion returns the region local-format phones imported to an account are
+// parsed with: the one of the account if set, else the one of its company.
+func (s *LeadImportService) PhoneRegion(settings models.ImportSettings, accountID int) string {
+	var account models.MessagingAccount
+	err := s.DB.Where("id = ? AND company_id = ? AND is_deleted = false", accountID, settings.CompanyID).First(&account).Error
+	if err == nil && account.PhoneRegion != nil {
+		if region, ok := validation.NormalizeRegion(*account.PhoneRegion); ok {
+			return region
+		}
+	}
+	return settings.PhoneRegion
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"leads-import/models"
+	"leads-import/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetImportSettings(t *testing.T) {
+	s := newTestService(t)
+	require.NoError(t, s.DB.Create(&models.ImportSettings{CompanyID: 2, MaxRows: 20000}).Error)
+
+	settings, err := s.GetImportSettings(1)
+	require.NoError(t, err)
+	assert.Equal(t, validation.DefaultMaxRows, settings.MaxRows)
+
+	t.Setenv("IMPORT_MAX_ROWS", "100")
+	settings, err = s.GetImportSettings(1)
+	require.NoError(t, err)
+	assert.Equal(t, 100, settings.MaxRows)
+
+	// A company override wins over the default
+	settings, err = s.GetImportSettings(2)
+	require.NoError(t, err)
+	assert.Equal(t, 20000, settings.MaxRows)
+}
+
+func TestPhoneRegion(t *testing.T) {
+	s := newTestService(t)
+	pt, invalid := "pt", "XX"
+	require.NoError(t, s.DB.Create(&[]models.MessagingAccount{
+		{ID: 1, CompanyID: 1},
+		{ID: 2, CompanyID: 1, PhoneRegion: &pt},
+		{ID: 3, CompanyID: 1, PhoneRegion: &invalid},
+		{ID: 4, CompanyID: 2, PhoneRegion: &pt},
+	}).Error)
+	require.NoError(t, s.DB.Create(&models.ImportSettings{CompanyID: 2, PhoneRegion: "US"}).Error)
+
+	tests := []struct {
+		name      string
+		companyID int
+		accountID int
+		want      string
+	}{
+		{"default", 1, 1, validation.DefaultPhoneRegion},
+		{"account override", 1, 2, "PT"},
+		{"invalid account region", 1, 3, validation.DefaultPhoneRegion},
+		{"company setting", 2, 1, "US"},
+		{"account of the company", 2, 4, "PT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := s.GetImportSettings(tt.companyID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.PhoneRegion(settings, tt.accountID))
+		})
+	}
+
+	t.Setenv("IMPORT_DEFAULT_PHONE_REGION", "+351")
+	settings, err := s.GetImportSettings(1)
+	require.NoError(t, err)
+	assert.Equal(t, "PT", settings.PhoneRegion)
+}
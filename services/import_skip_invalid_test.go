@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartImportStagesInvalidRows(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record, err := s.StartImport(StartImportInput{
+		Request:   models.ImportRequest{Name: "planilha", SourceID: 1, AccountID: 1, SkipInvalidRows: true},
+		CompanyID: 1,
+		UserID:    1,
+	}, openTestCSV(t, "name,phone\nAna,11987654321\nBia,123\n,11912345678\n"))
+	require.NoError(t, err)
+	importID := record.ID
+	assert.Equal(t, 2, reloadImport(t, s.DB, importID).TotalInvalid)
+
+	var invalid models.ImportRow
+	require.NoError(t, s.DB.Where("import_id = ? AND row_number = 3", importID).First(&invalid).Error)
+	require.NotNil(t, invalid.Outcome)
+	assert.Equal(t, models.ImportRowOutcomeInvalid, *invalid.Outcome)
+	require.NotNil(t, invalid.ErrorMessage)
+	assert.Equal(t, "phone: invalid phone number", *invalid.ErrorMessage)
+	assert.Equal(t, []string{"Bia", "123"}, invalid.RawCells)
+
+	// Only the valid row is processed, the invalid ones are not counted as errors
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	require.NoError(t, s.processImport(context.Background(), importID, input, true))
+	finished := reloadImport(t, s.DB, importID)
+	assert.Equal(t, models.LeadImportStatusFinished, finished.Status)
+	assert.Equal(t, 1, finished.TotalCreated)
+	assert.Equal(t, 0, finished.TotalErrors)
+	assert.Equal(t, 2, finished.TotalInvalid)
+	assert.Equal(t, map[int]models.ImportRowOutcome{
+		2: models.ImportRowOutcomeCreated,
+		3: models.ImportRowOutcomeInvalid,
+		4: models.ImportRowOutcomeInvalid,
+	}, rowOutcomes(t, s.DB, importID))
+
+	// The report lists them with their errors
+	report, err := s.BuildImportReport(1, importID)
+	require.NoError(t, err)
+	rows := reportRows(t, report)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"Bia", "123", "", "", "", "invalid", "phone: invalid phone number", ""}, rows[1])
+}
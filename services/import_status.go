@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"leads-import/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrImportNotFound = errors.New("import not found")
+
+// ImportStatus is a lead_imports record enriched with the live progress of the import.
+type ImportStatus struct {
+	models.LeadImport
+	TotalRows     int  `json:"total_rows,omitempty"`
+	ProcessedRows int  `json:"processed_rows"`
+	ETASeconds    *int `json:"eta_seconds"`
+}
+
+func (s *LeadImportService) GetImport(companyID int, importID int) (*ImportStatus, error) {
+	var record models.LeadImport
+	err := s.DB.Where("id = ? AND company_id = ?", importID, companyID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load import: %w", err)
+	}
+
+	status := &ImportStatus{LeadImport: record}
+
+	// While the import runs here the persisted totals are not updated yet: use the live counters.
+	progress, ok := s.Tracker.Get(importID)
+	if !ok {
+		status.ProcessedRows = record.TotalCreated + record.TotalExisting + record.TotalErrors
+		return status, nil
+	}
+	status.TotalCreated = progress.TotalCreated
+	status.TotalExisting = progress.TotalExisting
+	status.TotalErrors = progress.TotalErrors
+	status.TotalRows = progress.TotalRows
+	status.ProcessedRows = progress.ProcessedRows
+	if eta, ok := progress.ETA(); ok && record.Status == models.LeadImportStatusProcessing {
+		seconds := int(eta.Seconds())
+		status.ETASeconds = &seconds
+	}
+
+	return status, nil
+}
+
+// saveProgress persists the running totals so they are visible from every instance.
+func (s *LeadImportService) saveProgress(importID int, created int, existing int, failed int) {
+	s.DB.Table("amigocare.lead_imports").Where("id = ?", importID).Updates(map[string]interface{}{
+		"total_created":  created,
+		"total_existing": existing,
+		"total_errors":   failed,
+		"updated_at":     time.Now(),
+	})
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ImportProgress holds the live counters of an import running in this process.
+type ImportProgress struct {
+	cancel context.CancelCauseFunc
+
+	TotalRows     int
+	ProcessedRows int
+	TotalCreated  int
+	TotalExisting int
+	TotalErrors   int
+	// StartedAt is when this attempt started, and StartedRows how many rows
+	// earlier attempts had processed by then
+	StartedAt   time.Time
+	StartedRows int
+}
+
+// ETA estimates the remaining time from the average time spent per row processed
+// since this attempt started.
+func (p ImportProgress) ETA() (time.Duration, bool) {
+	processed := p.ProcessedRows - p.StartedRows
+	if processed <= 0 || p.TotalRows == 0 {
+		return 0, false
+	}
+	remaining := p.TotalRows - p.ProcessedRows
+	if remaining <= 0 {
+		return 0, true
+	}
+	perRow := time.Since(p.StartedAt) / time.Duration(processed)
+	return perRow * time.Duration(remaining), true
+}
+
+// ImportTracker keeps track of the imports being processed by this instance and
+// notifies subscribers whenever their progress changes.
+type ImportTracker struct {
+	mu          sync.Mutex
+	running     map[int]*ImportProgress
+	subscribers map[int]map[chan struct{}]struct{}
+}
+
+func NewImportTracker() *ImportTracker {
+	return &ImportTracker{
+		running:     make(map[int]*ImportProgress),
+		subscribers: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+// start registers an import, of which processedRows were processed by earlier
+// attempts, and returns the context its processing must honor.
+func (t *ImportTracker) start(parent context.Context, importID int, totalRows int, processedRows int) context.Context {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running[importID] = &ImportProgress{
+		cancel:        cancel,
+		TotalRows:     totalRows,
+		ProcessedRows: processedRows,
+		StartedAt:     time.Now(),
+		StartedRows:   processedRows,
+	}
+	return ctx
+}
+
+func (t *ImportTracker) set(importID int, created int, existing int, failed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.running[importID]; ok {
+		p.TotalCreated = created
+		p.TotalExisting = existing
+		p.TotalErrors = failed
+		p.ProcessedRows = created + existing + failed
+	}
+}
+
+// cancel stops a running import. It reports false if the import is not running here.
+func (t *ImportTracker) cancel(importID int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.running[importID]
+	if ok {
+		p.cancel(ErrImportCancelled)
+	}
+	return ok
+}
+
+func (t *ImportTracker) finish(importID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.running[importID]; ok {
+		p.cancel(nil)
+		delete(t.running, importID)
+	}
+}
+
+// Get returns a snapshot of the progress of a running import.
+func (t *ImportTracker) Get(importID int) (ImportProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.running[importID]
+	if !ok {
+		return ImportProgress{}, false
+	}
+	return *p, true
+}
+
+// Subscribe returns a channel signaled whenever the import makes progress or
+// finishes, and a function to unsubscribe. Signals are coalesced, never queued.
+func (t *ImportTracker) Subscribe(importID int) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subscribers[importID] == nil {
+		t.subscribers[importID] = make(map[chan struct{}]struct{})
+	}
+	t.subscribers[importID][ch] = struct{}{}
+
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subscribers[importID], ch)
+		if len(t.subscribers[importID]) == 0 {
+			delete(t.subscribers, importID)
+		}
+	}
+}
+
+func (t *ImportTracker) notify(importID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.subscribers[importID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
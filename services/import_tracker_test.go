@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportTrackerSubscribe(t *testing.T) {
+	tracker := NewImportTracker()
+	updates, unsubscribe := tracker.Subscribe(1)
+	other, unsubscribeOther := tracker.Subscribe(2)
+	defer unsubscribeOther()
+
+	// Signals are coalesced
+	tracker.notify(1)
+	tracker.notify(1)
+	assert.Len(t, updates, 1)
+	assert.Empty(t, other)
+	<-updates
+
+	unsubscribe()
+	tracker.notify(1)
+	assert.Empty(t, updates)
+	assert.NotContains(t, tracker.subscribers, 1)
+	assert.Contains(t, tracker.subscribers, 2)
+}
+
+func TestImportProgressETA(t *testing.T) {
+	// Resumed with 50 of 100 rows done, then 10 rows in 10 seconds
+	progress := ImportProgress{TotalRows: 100, ProcessedRows: 50, StartedRows: 50, StartedAt: time.Now().Add(-10 * time.Second)}
+	_, ok := progress.ETA()
+	assert.False(t, ok)
+
+	progress.ProcessedRows = 60
+	eta, ok := progress.ETA()
+	assert.True(t, ok)
+	assert.InDelta(t, 40*time.Second, eta, float64(time.Second))
+
+	progress.ProcessedRows = 100
+	eta, ok = progress.ETA()
+	assert.True(t, ok)
+	assert.Zero(t, eta)
+}
@@ -29,7 +29,10 @@ func GetImportService() *LeadImportService {
 			WhatsApp: &NoopWhatsAppValidator{},
 			Events:   &NoopEventEmitter{},
 			Cache:    &NoopCacheClearer{},
+			Tracker:  NewImportTracker(),
+			Limits:   UploadLimitsFromEnv(),
 		}
+		importService.Queue = NewImportQueue(db, ImportQueueConfigFromEnv(), importService.runImportJob)
 	})
 	return importService
 }
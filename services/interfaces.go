@@ -5,6 +5,7 @@ import "context"
 type Chat struct {
 	ID        string
 	Phone     string
+	DialCode  string
 	AccountID int
 	CompanyID int
 	LeadID    *int
@@ -14,6 +15,8 @@ type ChatRepository interface {
 	FindChatsByPhones(ctx context.Context, phones []string, accountID int, companyID int) ([]Chat, error)
 	CreateChat(ctx context.Context, phone string, dialCode string, countryCode string, accountID int, companyID int) (string, error)
 	UpdateChatLeadID(ctx context.Context, chatID string, leadID int) error
+	// DeleteChats deletes the given chats, ignoring the ones already deleted
+	DeleteChats(ctx context.Context, chatIDs []string) error
 }
 
 type WhatsAppValidator interface {
@@ -2,45 +2,77 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
 
 	"leads-import/models"
+	"leads-import/validation"
 
 	"gorm.io/gorm"
 )
 
 const ChunkSize = 100
 
+// importPageSize is how many staged rows processImport loads at a time
+const importPageSize = 1000
+
 type LeadImportService struct {
 	DB       *gorm.DB
 	Chats    ChatRepository
 	WhatsApp WhatsAppValidator
 	Events   EventEmitter
 	Cache    CacheClearer
+	Tracker  *ImportTracker
+	Queue    *ImportQueue
+	Limits   UploadLimits
 }
 
+// StartImportInput is also the payload of the import job. Rows are staged in
+// lead_import_rows instead and the token is never persisted.
 type StartImportInput struct {
 	Request   models.ImportRequest
-	Rows      []models.ParsedRow
+	Header    []string           `json:"-"`
+	Rows      []models.ParsedRow `json:"-"` // valid rows of a dry run, an import stages them from the file
 	CompanyID int
 	UserID    int
-	Token     string
+	Token     string `json:"-"`
+
+	// Optional Idempotency-Key of the upload and the hash of its payload
+	IdempotencyKey string `json:"-"`
+	RequestHash    string `json:"-"`
+}
+
+// stageBatchSize is how many parsed rows are staged per insert
+const stageBatchSize = 500
+
+// InvalidRowsError rejects an import because of the rows of its file. Errors
+// lists every row error found.
+type InvalidRowsError struct {
+	Message string
+	Errors  []validation.RowError
 }
 
-func (s *LeadImportService) StartImport(input StartImportInput) (int, error) {
+func (e *InvalidRowsError) Error() string {
+	return e.Message
+}
+
+// validateImport runs the checks an import must pass before it is created.
+func (s *LeadImportService) validateImport(input StartImportInput) error {
 	// 1. Validate source_id exists
 	var source models.LeadSource
 	if err := s.DB.Where("id = ? AND is_deleted = false", input.Request.SourceID).First(&source).Error; err != nil {
-		return 0, fmt.Errorf("invalid source_id: source not found")
+		return fmt.Errorf("invalid source_id: source not found")
 	}
 
 	// 2. Validate account_id exists and belongs to company
 	var account models.MessagingAccount
 	if err := s.DB.Where("id = ? AND company_id = ? AND is_deleted = false", input.Request.AccountID, input.CompanyID).First(&account).Error; err != nil {
-		return 0, fmt.Errorf("invalid account_id: account not found or does not belong to company")
+		return fmt.Errorf("invalid account_id: account not found or does not belong to company")
 	}
 
 	// 3. Validate import name uniqueness
@@ -49,12 +81,12 @@ func (s *LeadImportService) StartImport(input StartImportInput) (int, error) {
 		Where("name = ? AND account_id = ? AND company_id = ? AND is_deleted = false", input.Request.Name, input.Request.AccountID, input.CompanyID).
 		Count(&existingCount)
 	if existingCount > 0 {
-		return 0, fmt.Errorf("import name already exists for this account")
+		return fmt.Errorf("import name already exists for this account")
 	}
 
 	// 4. Validate tag_ids if provided
 	if len(input.Request.TagIDs) > 5 {
-		return 0, fmt.Errorf("max 5 tag_ids allowed")
+		return fmt.Errorf("max 5 tag_ids allowed")
 	}
 	if len(input.Request.TagIDs) > 0 {
 		var tagCount int64
@@ -62,16 +94,24 @@ func (s *LeadImportService) StartImport(input StartImportInput) (int, error) {
 			Where("id IN ? AND company_id = ? AND is_deleted = false", input.Request.TagIDs, input.CompanyID).
 			Count(&tagCount)
 		if int(tagCount) != len(input.Request.TagIDs) {
-			return 0, fmt.Errorf("one or more tag_ids are invalid")
+			return fmt.Errorf("one or more tag_ids are invalid")
 		}
 	}
 
 	// 5. Check rate limit
-	if err := CheckRateLimit(s.DB, input.CompanyID, input.Request.AccountID); err != nil {
-		return 0, err
+	return CheckRateLimit(s.DB, input.CompanyID, input.Request.AccountID)
+}
+
+// StartImport creates an import and stages the rows of its file as they are
+// parsed, in batches, so the file is never held in memory. Invalid rows are
+// staged as such when Request.SkipInvalidRows is set and reject the import with
+// an *InvalidRowsError otherwise.
+func (s *LeadImportService) StartImport(input StartImportInput, parser *validation.Parser) (*models.LeadImport, error) {
+	if err := s.validateImport(input); err != nil {
+		return nil, err
 	}
 
-	// 6. Insert lead_imports record
+	// Insert lead_imports record
 	importRecord := models.LeadImport{
 		Name:      input.Request.Name,
 		Status:    models.LeadImportStatusProcessing,
@@ -79,129 +119,167 @@ func (s *LeadImportService) StartImport(input StartImportInput) (int, error) {
 		CreatorID: input.UserID,
 		SourceID:  input.Request.SourceID,
 		AccountID: input.Request.AccountID,
+		Header:    input.Header,
 	}
-	if err := s.DB.Create(&importRecord).Error; err != nil {
-		return 0, fmt.Errorf("failed to create import record: %w", err)
-	}
+	// Stage the rows and enqueue async processing along with the record
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&importRecord).Error; err != nil {
+			return fmt.Errorf("failed to create import record: %w", err)
+		}
+		// Claimed first so a concurrent retry waits on the key instead of staging
+		if err := saveIdempotencyKey(tx, input, importRecord.ID); err != nil {
+			return err
+		}
 
-	// 7. Launch async processing
-	go s.processImport(importRecord.ID, input)
+		var rowErrors []validation.RowError
+		validRows := 0
+		batch := make([]models.ImportRow, 0, stageBatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := tx.Create(&batch).Error; err != nil {
+				return fmt.Errorf("failed to stage import rows: %w", err)
+			}
+			batch = batch[:0]
+			return nil
+		}
 
-	return importRecord.ID, nil
-}
+		for {
+			row, errs, err := parser.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("file validation failed: %w", err)
+			}
 
-func (s *LeadImportService) processImport(importID int, input StartImportInput) {
-	ctx := context.Background()
+			if len(errs) > 0 {
+				rowErrors = append(rowErrors, errs...)
+				importRecord.TotalInvalid++
+				// Nothing is staged once the import is known to be rejected
+				if !input.Request.SkipInvalidRows {
+					continue
+				}
+				batch = append(batch, models.NewInvalidImportRow(importRecord.ID, validation.InvalidRow(row, errs)))
+			} else {
+				validRows++
+				if len(rowErrors) > 0 && !input.Request.SkipInvalidRows {
+					continue
+				}
+				batch = append(batch, models.NewImportRow(importRecord.ID, row))
+			}
 
-	totalCreated := 0
-	totalExisting := 0
-	totalErrors := 0
-	finalStatus := models.LeadImportStatusFinished
+			if len(batch) == stageBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
 
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("panic in processImport: %v", r)
-			finalStatus = models.LeadImportStatusFailed
+		if len(rowErrors) > 0 && !input.Request.SkipInvalidRows {
+			return &InvalidRowsError{Message: "file contains invalid rows", Errors: rowErrors}
+		}
+		if validRows == 0 {
+			return &InvalidRowsError{Message: "file has no valid rows", Errors: rowErrors}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := tx.Model(&importRecord).Update("total_invalid", importRecord.TotalInvalid).Error; err != nil {
+			return fmt.Errorf("failed to save the invalid row count: %w", err)
 		}
-		s.DB.Table("amigocare.lead_imports").Where("id = ?", importID).Updates(map[string]interface{}{
-			"status":         string(finalStatus),
-			"total_created":  totalCreated,
-			"total_existing": totalExisting,
-			"total_errors":   totalErrors,
-			"updated_at":     time.Now(),
-		})
-
-		_ = s.Cache.ClearLeadCache(ctx, input.CompanyID)
-		_ = s.Events.Emit(ctx, "lead:import-finished", map[string]interface{}{
-			"import_id":  importID,
-			"company_id": input.CompanyID,
-		})
-	}()
 
-	// 1. Filter duplicates
-	phones := make([]string, len(input.Rows))
-	for i, row := range input.Rows {
-		phones[i] = row.Phone
+		return s.Queue.Enqueue(tx, importRecord.ID, input)
+	})
+	if err != nil {
+		if input.IdempotencyKey != "" {
+			// A concurrent upload with the same key created its import first
+			if importID, findErr := s.FindIdempotentImport(input.CompanyID, input.UserID, input.IdempotencyKey, input.RequestHash); findErr != nil || importID != 0 {
+				if findErr != nil {
+					return nil, findErr
+				}
+				return nil, &IdempotentReplayError{ImportID: importID}
+			}
+		}
+		return nil, err
 	}
 
-	// Check existing leads by phone
-	var existingLeadPhones []string
-	s.DB.Model(&models.Lead{}).
-		Where("contact_cellphone IN ? AND company_id = ? AND amigocare_messaging_account_id = ? AND is_deleted = false",
-			phones, input.CompanyID, input.Request.AccountID).
-		Pluck("contact_cellphone", &existingLeadPhones)
-
-	// Check existing patients by phone
-	var existingPatientPhones []string
-	s.DB.Model(&models.Patient{}).
-		Where("contact_cellphone IN ? AND company_id = ? AND deleted_at IS NULL",
-			phones, input.CompanyID).
-		Pluck("contact_cellphone", &existingPatientPhones)
+	return &importRecord, nil
+}
 
-	// Check existing chats in MongoDB
-	existingChats, err := s.Chats.FindChatsByPhones(ctx, phones, input.Request.AccountID, input.CompanyID)
-	if err != nil {
-		log.Printf("failed to check existing chats: %v", err)
-		finalStatus = models.LeadImportStatusFailed
-		return
-	}
-	existingChatPhones := make(map[string]bool)
-	for _, chat := range existingChats {
-		existingChatPhones[chat.Phone] = true
+// runImportJob is the ImportQueue handler processing a queued import.
+func (s *LeadImportService) runImportJob(ctx context.Context, job *models.ImportJob, finalAttempt bool) error {
+	var input StartImportInput
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return fmt.Errorf("failed to decode import job: %w", err)
 	}
 
-	// Build duplicate set
-	duplicatePhones := make(map[string]bool)
-	for _, p := range existingLeadPhones {
-		duplicatePhones[p] = true
-	}
-	for _, p := range existingPatientPhones {
-		duplicatePhones[p] = true
+	return s.processImport(ctx, job.ImportID, input, finalAttempt)
+}
+
+// processImport creates the leads of the staged rows that have no outcome yet,
+// so a retried import resumes where the previous attempt stopped. When it returns
+// an error and this is not the final attempt, the import is left PROCESSING.
+func (s *LeadImportService) processImport(ctx context.Context, importID int, input StartImportInput, finalAttempt bool) (err error) {
+	finalStatus := models.LeadImportStatusFinished
+
+	// Failed by recovery, cancelled while queued or rolled back before this
+	// attempt started: there is nothing left to do
+	var record models.LeadImport
+	if err := s.DB.Where("id = ?", importID).First(&record).Error; err != nil {
+		return fmt.Errorf("failed to load import: %w", err)
 	}
-	for p := range existingChatPhones {
-		duplicatePhones[p] = true
+	if record.Status != models.LeadImportStatusProcessing {
+		return nil
 	}
 
-	// Separate duplicates from non-duplicates
-	var nonDuplicates []models.ParsedRow
-	for _, row := range input.Rows {
-		if duplicatePhones[row.Phone] {
-			totalExisting++
-		} else {
-			nonDuplicates = append(nonDuplicates, row)
-		}
+	// Rows processed by a previous attempt
+	totalCreated, totalExisting, totalErrors, err := s.countRowOutcomes(importID)
+	if err != nil {
+		return err
 	}
 
-	if len(nonDuplicates) == 0 {
-		return
+	var pendingRows int64
+	if err := s.DB.Model(&models.ImportRow{}).Where("import_id = ? AND outcome IS NULL", importID).Count(&pendingRows).Error; err != nil {
+		return fmt.Errorf("failed to load import rows: %w", err)
 	}
 
-	// 2. Resolve tags
-	allTagNames := make(map[string]bool)
-	for _, row := range nonDuplicates {
-		for _, t := range row.TagNames {
-			allTagNames[t] = true
-		}
+	processedRows := totalCreated + totalExisting + totalErrors
+	ctx = s.Tracker.start(ctx, importID, processedRows+int(pendingRows), processedRows)
+	defer s.Tracker.finish(importID)
+	reportProgress := func() {
+		s.Tracker.set(importID, totalCreated, totalExisting, totalErrors)
 	}
 
-	tagNameToID := make(map[string]int)
-	for name := range allTagNames {
-		var tag models.Tag
-		err := s.DB.Where("LOWER(name) = LOWER(?) AND company_id = ? AND is_deleted = false", name, input.CompanyID).First(&tag).Error
-		if err != nil {
-			tag = models.Tag{
-				Name:      name,
-				CompanyID: input.CompanyID,
-				CreatorID: input.UserID,
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
-			if err := s.DB.Create(&tag).Error; err != nil {
-				log.Printf("failed to create tag '%s': %v", name, err)
-				continue
-			}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in processImport: %v", r)
 		}
-		tagNameToID[strings.ToLower(name)] = tag.ID
+		switch {
+		case errors.Is(err, ErrImportCancelled):
+			finalStatus = models.LeadImportStatusCancelled
+		case errors.Is(err, errLeaseLost), errors.Is(err, errShutdown):
+			return
+		case err != nil && !finalAttempt:
+			return
+		case err != nil:
+			log.Printf("import %d failed: %v", importID, err)
+			finalStatus = models.LeadImportStatusFailed
+		}
+		var failure error
+		if finalStatus == models.LeadImportStatusFailed {
+			failure = err
+		}
+		s.finalizeImport(importID, input.CompanyID, finalStatus, totalCreated, totalExisting, totalErrors, failure)
+	}()
+
+	// Cancelled while its worker was down
+	if s.isCancelRequested(importID) {
+		return ErrImportCancelled
+	}
+	if pendingRows == 0 {
+		return nil
 	}
 
 	// Add tag_ids from request
@@ -214,117 +292,409 @@ func (s *LeadImportService) processImport(importID int, input StartImportInput)
 		requestTagIDs = append(requestTagIDs, t.ID)
 	}
 
-	// 3. Get IMPORT channel ID
+	tagNameToID := make(map[string]int)
 	var importChannel models.LeadChannel
-	if err := s.DB.Where("LOWER(name) = 'import' AND is_deleted = false").First(&importChannel).Error; err != nil {
-		log.Printf("failed to find IMPORT channel: %v", err)
-		finalStatus = models.LeadImportStatusFailed
-		return
-	}
 
-	// 4. Process non-duplicates in chunks
-	for i := 0; i < len(nonDuplicates); i += ChunkSize {
-		end := i + ChunkSize
-		if end > len(nonDuplicates) {
-			end = len(nonDuplicates)
+	// Phones of the leads created by this run, so a contact listed twice in the
+	// file, maybe once without its 9th digit, gets a single lead. The leads of
+	// earlier pages and attempts are found by findDuplicates.
+	createdPhones := make(map[phoneKey]bool)
+
+	// The pending rows are processed a page at a time, so a large import is never
+	// loaded at once. Pages are walked by row number as failed outcome updates
+	// leave rows pending.
+	lastRowNumber := 0
+	for {
+		var rows []models.ImportRow
+		if err := s.DB.Where("import_id = ? AND outcome IS NULL AND row_number > ?", importID, lastRowNumber).
+			Order("row_number").
+			Limit(importPageSize).
+			Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to load import rows: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
 		}
-		chunk := nonDuplicates[i:end]
+		lastRowNumber = rows[len(rows)-1].RowNumber
 
-		for _, row := range chunk {
-			valid, err := s.WhatsApp.ValidatePhone(ctx, row.Phone, input.Request.AccountID)
-			if err != nil {
-				log.Printf("WhatsApp validation error for %s: %v", row.Phone, err)
-				totalErrors++
-				continue
+		// 1. Filter duplicates
+		phones := make(map[phoneKey]bool, len(rows))
+		for _, row := range rows {
+			phones[phoneKey{DialCode: row.DialCode, Phone: row.Phone}] = true
+		}
+
+		duplicatePhones, err := s.findDuplicates(ctx, phones, input.CompanyID, input.Request.AccountID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
 			}
-			if !valid {
-				totalErrors++
-				continue
+			return err
+		}
+
+		// Separate duplicates from non-duplicates
+		var nonDuplicates []models.ImportRow
+		for i := range rows {
+			if outcome, ok := duplicatePhones[phoneKey{DialCode: rows[i].DialCode, Phone: rows[i].Phone}]; ok {
+				s.recordRowOutcome(&rows[i], outcome, "")
+				totalExisting++
+			} else {
+				nonDuplicates = append(nonDuplicates, rows[i])
 			}
+		}
+		reportProgress()
 
-			chatID, err := s.Chats.CreateChat(ctx, row.Phone, row.DialCode, row.CountryCode, input.Request.AccountID, input.CompanyID)
-			if err != nil {
-				log.Printf("failed to create chat for %s: %v", row.Phone, err)
-				totalErrors++
-				continue
+		if len(nonDuplicates) == 0 {
+			continue
+		}
+
+		// 2. Resolve tags
+		allTagNames := make(map[string]bool)
+		for _, row := range nonDuplicates {
+			for _, t := range row.TagNames {
+				allTagNames[t] = true
 			}
+		}
 
-			var namePtr *string
-			if row.Name != "" {
-				n := row.Name
-				namePtr = &n
+		for name := range allTagNames {
+			if _, ok := tagNameToID[strings.ToLower(name)]; ok {
+				continue
 			}
-			var emailPtr *string
-			if row.Email != "" {
-				e := row.Email
-				emailPtr = &e
+			var tag models.Tag
+			err := s.DB.Where("LOWER(name) = LOWER(?) AND company_id = ? AND is_deleted = false", name, input.CompanyID).First(&tag).Error
+			if err != nil {
+				tag = models.Tag{
+					Name:      name,
+					CompanyID: input.CompanyID,
+					CreatorID: input.UserID,
+					ImportID:  &importID,
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				}
+				if err := s.DB.Create(&tag).Error; err != nil {
+					log.Printf("failed to create tag '%s': %v", name, err)
+					continue
+				}
 			}
-			var cpfPtr *string
-			if row.CPF != "" {
-				c := row.CPF
-				cpfPtr = &c
+			tagNameToID[strings.ToLower(name)] = tag.ID
+		}
+
+		// 3. Get IMPORT channel ID
+		if importChannel.ID == 0 {
+			if err := s.DB.Where("LOWER(name) = 'import' AND is_deleted = false").First(&importChannel).Error; err != nil {
+				return fmt.Errorf("failed to find IMPORT channel: %w", err)
 			}
+		}
 
-			lead := models.Lead{
-				Name:                        namePtr,
-				Email:                       emailPtr,
-				CPF:                         cpfPtr,
-				ContactCellphone:            row.Phone,
-				ContactCellphoneDialCode:    row.DialCode,
-				ContactCellphoneCountryCode: row.CountryCode,
-				SourceID:                    input.Request.SourceID,
-				ChannelID:                   importChannel.ID,
-				ChatID:                      &chatID,
-				ImportID:                    importID,
-				CompanyID:                   input.CompanyID,
-				AmigocareMessagingAccountID: input.Request.AccountID,
-				CreatorID:                   input.UserID,
-				IsDeleted:                   false,
-				CreatedAt:                   time.Now(),
-				UpdatedAt:                   time.Now(),
+		// 4. Process non-duplicates in chunks
+		for i := 0; i < len(nonDuplicates); i += ChunkSize {
+			end := i + ChunkSize
+			if end > len(nonDuplicates) {
+				end = len(nonDuplicates)
 			}
+			chunk := nonDuplicates[i:end]
 
-			if err := s.DB.Create(&lead).Error; err != nil {
-				log.Printf("failed to create lead for %s: %v", row.Phone, err)
-				totalErrors++
-				continue
+			// The cancellation may have been requested on another instance
+			if s.isCancelRequested(importID) {
+				s.Tracker.cancel(importID)
 			}
+			reportProgress()
+			s.saveProgress(importID, totalCreated, totalExisting, totalErrors)
+			s.Tracker.notify(importID)
 
-			if err := s.Chats.UpdateChatLeadID(ctx, chatID, lead.ID); err != nil {
-				log.Printf("failed to update chat lead ID: %v", err)
+			// Checkpoint: processed rows keep their outcome, the rest resumes after a restart
+			if s.Queue.Draining() {
+				return errShutdown
 			}
 
-			// Create chat_tags
-			rowTagIDs := make([]int, 0)
-			for _, tagName := range row.TagNames {
-				if id, ok := tagNameToID[strings.ToLower(tagName)]; ok {
-					rowTagIDs = append(rowTagIDs, id)
+			for j := range chunk {
+				if ctx.Err() != nil {
+					return context.Cause(ctx)
 				}
-			}
-			rowTagIDs = append(rowTagIDs, requestTagIDs...)
+				reportProgress()
 
-			seen := make(map[int]bool)
-			for _, id := range rowTagIDs {
-				if seen[id] {
+				row := &chunk[j]
+				key := phoneKey{DialCode: row.DialCode, Phone: row.Phone}
+				if createdPhones[key] {
+					s.recordRowOutcome(row, models.ImportRowOutcomeDuplicateLead, "")
+					totalExisting++
 					continue
 				}
-				seen[id] = true
-				chatTag := models.ChatTag{
-					ChatID:    chatID,
-					TagID:     id,
-					LeadID:    lead.ID,
-					CompanyID: input.CompanyID,
-					CreatorID: input.UserID,
-					IsDeleted: false,
-					CreatedAt: time.Now(),
-					UpdatedAt: time.Now(),
+
+				valid, err := s.WhatsApp.ValidatePhone(ctx, row.Phone, input.Request.AccountID)
+				if err != nil {
+					// A cancelled or shut down import leaves the row pending, not failed
+					if ctx.Err() != nil {
+						return context.Cause(ctx)
+					}
+					log.Printf("WhatsApp validation error for %s: %v", row.Phone, err)
+					s.recordRowOutcome(row, models.ImportRowOutcomeError, "WhatsApp validation failed: "+err.Error())
+					totalErrors++
+					continue
+				}
+				if !valid {
+					s.recordRowOutcome(row, models.ImportRowOutcomeWhatsAppInvalid, "phone is not registered on WhatsApp")
+					totalErrors++
+					continue
+				}
+
+				chatID, err := s.Chats.CreateChat(ctx, row.Phone, row.DialCode, row.CountryCode, input.Request.AccountID, input.CompanyID)
+				if err != nil {
+					if ctx.Err() != nil {
+						return context.Cause(ctx)
+					}
+					log.Printf("failed to create chat for %s: %v", row.Phone, err)
+					s.recordRowOutcome(row, models.ImportRowOutcomeError, err.Error())
+					totalErrors++
+					continue
+				}
+				row.ChatID = &chatID
+
+				var namePtr *string
+				if row.Name != "" {
+					n := row.Name
+					namePtr = &n
+				}
+				var emailPtr *string
+				if row.Email != "" {
+					e := row.Email
+					emailPtr = &e
+				}
+				var cpfPtr *string
+				if row.CPF != "" {
+					c := row.CPF
+					cpfPtr = &c
 				}
-				if err := s.DB.Create(&chatTag).Error; err != nil {
-					log.Printf("failed to create chat_tag: %v", err)
+
+				lead := models.Lead{
+					Name:                        namePtr,
+					Email:                       emailPtr,
+					CPF:                         cpfPtr,
+					ContactCellphone:            row.Phone,
+					ContactCellphoneDialCode:    row.DialCode,
+					ContactCellphoneCountryCode: row.CountryCode,
+					ContactCellphoneRegion:      row.PhoneRegion,
+					ContactCellphoneType:        row.PhoneType,
+					SourceID:                    input.Request.SourceID,
+					ChannelID:                   importChannel.ID,
+					ChatID:                      &chatID,
+					ImportID:                    importID,
+					CompanyID:                   input.CompanyID,
+					AmigocareMessagingAccountID: input.Request.AccountID,
+					CreatorID:                   input.UserID,
+					CustomFields:                row.CustomFields,
+					IsDeleted:                   false,
+					CreatedAt:                   time.Now(),
+					UpdatedAt:                   time.Now(),
+				}
+
+				if err := s.DB.Create(&lead).Error; err != nil {
+					log.Printf("failed to create lead for %s: %v", row.Phone, err)
+					s.recordRowOutcome(row, models.ImportRowOutcomeError, "failed to create lead: "+err.Error())
+					totalErrors++
+					continue
+				}
+				row.LeadID = &lead.ID
+
+				// The chat exists now: link it to its lead even if the import is being stopped
+				if err := s.Chats.UpdateChatLeadID(context.WithoutCancel(ctx), chatID, lead.ID); err != nil {
+					log.Printf("failed to update chat lead ID: %v", err)
+				}
+
+				// Create chat_tags
+				rowTagIDs := make([]int, 0)
+				for _, tagName := range row.TagNames {
+					if id, ok := tagNameToID[strings.ToLower(tagName)]; ok {
+						rowTagIDs = append(rowTagIDs, id)
+					}
 				}
+				rowTagIDs = append(rowTagIDs, requestTagIDs...)
+
+				seen := make(map[int]bool)
+				for _, id := range rowTagIDs {
+					if seen[id] {
+						continue
+					}
+					seen[id] = true
+					chatTag := models.ChatTag{
+						ChatID:    chatID,
+						TagID:     id,
+						LeadID:    lead.ID,
+						CompanyID: input.CompanyID,
+						CreatorID: input.UserID,
+						IsDeleted: false,
+						CreatedAt: time.Now(),
+						UpdatedAt: time.Now(),
+					}
+					if err := s.DB.Create(&chatTag).Error; err != nil {
+						log.Printf("failed to create chat_tag: %v", err)
+					}
+				}
+
+				s.recordRowOutcome(row, models.ImportRowOutcomeCreated, "")
+				totalCreated++
+				createdPhones[key] = true
 			}
+		}
+	}
+}
+
+// finalizeImport stores the final status and totals of an import that is still
+// PROCESSING, then tells the listeners it is over. A rolled back or otherwise
+// finished import is left alone.
+func (s *LeadImportService) finalizeImport(importID int, companyID int, status models.LeadImportStatus, created int, existing int, failed int, failure error) {
+	updates := map[string]interface{}{
+		"status":         string(status),
+		"total_created":  created,
+		"total_existing": existing,
+		"total_errors":   failed,
+		"updated_at":     time.Now(),
+	}
+	if failure != nil {
+		updates["failure_reason"] = failure.Error()
+	}
+	if err := s.DB.Table("amigocare.lead_imports").
+		Where("id = ? AND status = ?", importID, string(models.LeadImportStatusProcessing)).
+		Updates(updates).Error; err != nil {
+		log.Printf("import %d: failed to store its final status: %v", importID, err)
+	}
+	s.Tracker.notify(importID)
+
+	// The context of the import may already be cancelled at this point
+	_ = s.Cache.ClearLeadCache(context.Background(), companyID)
+	_ = s.Events.Emit(context.Background(), "lead:import-finished", map[string]interface{}{
+		"import_id":  importID,
+		"company_id": companyID,
+		"status":     string(status),
+	})
+}
+
+// recordRowOutcome stores the result of a processed row along with its lead and chat, if any.
+func (s *LeadImportService) recordRowOutcome(row *models.ImportRow, outcome models.ImportRowOutcome, message string) {
+	updates := map[string]interface{}{
+		"outcome":    string(outcome),
+		"lead_id":    row.LeadID,
+		"chat_id":    row.ChatID,
+		"updated_at": time.Now(),
+	}
+	if message != "" {
+		updates["error_message"] = message
+	}
+	if err := s.DB.Model(&models.ImportRow{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+		log.Printf("failed to record outcome of import row %d: %v", row.ID, err)
+	}
+}
+
+// phoneKey identifies a contact phone: the same national number with another
+// dial code is another contact
+type phoneKey struct {
+	DialCode string
+	Phone    string
+}
+
+// matches reports whether a stored phone is k in one of the forms it may be
+// stored in. A contact stored without a dial code matches any.
+func (k phoneKey) matches(dialCode string) bool {
+	dialCode = strings.TrimPrefix(dialCode, "+")
+	return dialCode == "" || dialCode == k.DialCode
+}
+
+// findDuplicates maps the phones that already belong to a lead, patient or chat
+// of the company to the matching duplicate outcome. The most relevant reason wins.
+// A phone is looked up in every form it may be stored in, see
+// validation.PhoneVariants, importPageSize forms at a time, and matched on its
+// dial code too.
+func (s *LeadImportService) findDuplicates(ctx context.Context, phones map[phoneKey]bool, companyID int, accountID int) (map[phoneKey]models.ImportRowOutcome, error) {
+	storedAs := make(map[string][]phoneKey) // stored form -> phones
+	var lookups []string
+	for key := range phones {
+		for _, variant := range validation.PhoneVariants(key.Phone, key.DialCode) {
+			if _, ok := storedAs[variant]; !ok {
+				lookups = append(lookups, variant)
+			}
+			storedAs[variant] = append(storedAs[variant], key)
+		}
+	}
+
+	type storedPhone struct {
+		ContactCellphone         string
+		ContactCellphoneDialCode string
+	}
+	var existingLeadPhones, existingPatientPhones []storedPhone
+	var existingChats []Chat
+	for start := 0; start < len(lookups); start += importPageSize {
+		batch := lookups[start:min(start+importPageSize, len(lookups))]
+
+		// Check existing leads by phone
+		var leadPhones []storedPhone
+		s.DB.Model(&models.Lead{}).
+			Where("contact_cellphone IN ? AND company_id = ? AND amigocare_messaging_account_id = ? AND is_deleted = false",
+				batch, companyID, accountID).
+			Select("contact_cellphone, contact_cellphone_dial_code").
+			Scan(&leadPhones)
+		existingLeadPhones = append(existingLeadPhones, leadPhones...)
+
+		// Check existing patients by phone
+		var patientPhones []storedPhone
+		s.DB.Model(&models.Patient{}).
+			Where("contact_cellphone IN ? AND company_id = ? AND deleted_at IS NULL",
+				batch, companyID).
+			Select("contact_cellphone, contact_cellphone_dial_code").
+			Scan(&patientPhones)
+		existingPatientPhones = append(existingPatientPhones, patientPhones...)
+
+		// Check existing chats in MongoDB
+		chats, err := s.Chats.FindChatsByPhones(ctx, batch, accountID, companyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check existing chats: %w", err)
+		}
+		existingChats = append(existingChats, chats...)
+	}
+
+	duplicatePhones := make(map[phoneKey]models.ImportRowOutcome)
+	mark := func(phone string, dialCode string, outcome models.ImportRowOutcome) {
+		for _, key := range storedAs[phone] {
+			if key.matches(dialCode) {
+				duplicatePhones[key] = outcome
+			}
+		}
+	}
+	for _, chat := range existingChats {
+		mark(chat.Phone, chat.DialCode, models.ImportRowOutcomeDuplicateChat)
+	}
+	for _, p := range existingPatientPhones {
+		mark(p.ContactCellphone, p.ContactCellphoneDialCode, models.ImportRowOutcomeDuplicatePatient)
+	}
+	for _, p := range existingLeadPhones {
+		mark(p.ContactCellphone, p.ContactCellphoneDialCode, models.ImportRowOutcomeDuplicateLead)
+	}
+
+	return duplicatePhones, nil
+}
+
+// countRowOutcomes totals the staged rows of an import that were already processed.
+// Invalid rows are not processed and are left out.
+func (s *LeadImportService) countRowOutcomes(importID int) (created int, existing int, failed int, err error) {
+	var outcomes []struct {
+		Outcome models.ImportRowOutcome
+		Count   int
+	}
+	if err := s.DB.Model(&models.ImportRow{}).
+		Where("import_id = ? AND outcome IS NOT NULL AND outcome <> ?", importID, models.ImportRowOutcomeInvalid).
+		Select("outcome, COUNT(*) AS count").
+		Group("outcome").
+		Scan(&outcomes).Error; err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to load import rows: %w", err)
+	}
 
-			totalCreated++
+	for _, o := range outcomes {
+		switch {
+		case o.Outcome == models.ImportRowOutcomeCreated:
+			created += o.Count
+		case o.Outcome.IsDuplicate():
+			existing += o.Count
+		default:
+			failed += o.Count
 		}
 	}
+	return created, existing, failed, nil
 }
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTestImport stages rows for a new import and processes it
+func runTestImport(t *testing.T, s *LeadImportService, rows ...models.ParsedRow) models.LeadImport {
+	t.Helper()
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, rows...)
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	require.NoError(t, s.processImport(context.Background(), record.ID, input, true))
+	return reloadImport(t, s.DB, record.ID)
+}
+
+func TestProcessImportRecordsRowOutcomes(t *testing.T) {
+	s := newTestService(t)
+	s.Chats = &fakeChats{existing: []Chat{{ID: "chat-1", Phone: "11955554444"}}}
+	s.WhatsApp = fakeWhatsApp{"11933332222": true}
+	createTestAccount(t, s.DB)
+	require.NoError(t, s.DB.Create(&models.Lead{ContactCellphone: "11987654321", CompanyID: 1, AmigocareMessagingAccountID: 1}).Error)
+	require.NoError(t, s.DB.Create(&models.Patient{CompanyID: 1, ContactCellphone: "11912345678"}).Error)
+
+	record := runTestImport(t, s,
+		brazilianRow("11987654321"),
+		brazilianRow("11912345678"),
+		brazilianRow("11955554444"),
+		brazilianRow("11933332222"),
+		brazilianRow("11922221111"),
+	)
+
+	assert.Equal(t, map[int]models.ImportRowOutcome{
+		2: models.ImportRowOutcomeDuplicateLead,
+		3: models.ImportRowOutcomeDuplicatePatient,
+		4: models.ImportRowOutcomeDuplicateChat,
+		5: models.ImportRowOutcomeWhatsAppInvalid,
+		6: models.ImportRowOutcomeCreated,
+	}, rowOutcomes(t, s.DB, record.ID))
+	assert.Equal(t, models.LeadImportStatusFinished, record.Status)
+	assert.Equal(t, 1, record.TotalCreated)
+	assert.Equal(t, 3, record.TotalExisting)
+	assert.Equal(t, 1, record.TotalErrors)
+
+	var created models.ImportRow
+	require.NoError(t, s.DB.Where("import_id = ? AND row_number = 6", record.ID).First(&created).Error)
+	require.NotNil(t, created.LeadID)
+	require.NotNil(t, created.ChatID)
+	var lead models.Lead
+	require.NoError(t, s.DB.First(&lead, *created.LeadID).Error)
+	assert.Equal(t, "11922221111", lead.ContactCellphone)
+}
+
+func TestProcessImportResumesPendingRows(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, brazilianRow("11987654321"), brazilianRow("11912345678"))
+	// The previous attempt stopped after the first row
+	require.NoError(t, s.DB.Model(&models.ImportRow{}).Where("import_id = ? AND row_number = 2", record.ID).
+		Update("outcome", models.ImportRowOutcomeCreated).Error)
+
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	require.NoError(t, s.processImport(context.Background(), record.ID, input, true))
+
+	finished := reloadImport(t, s.DB, record.ID)
+	assert.Equal(t, 2, finished.TotalCreated)
+	var phones []string
+	require.NoError(t, s.DB.Model(&models.Lead{}).Where("import_id = ?", record.ID).Pluck("contact_cellphone", &phones).Error)
+	assert.Equal(t, []string{"11912345678"}, phones)
+}
+
+func TestProcessImportStopsAtACheckpointOnShutdown(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, brazilianRow("11987654321"))
+	s.Queue.Shutdown(time.Second)
+
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	err := s.processImport(context.Background(), record.ID, input, true)
+	assert.ErrorIs(t, err, errShutdown)
+
+	// Left for the next instance to resume
+	assert.Equal(t, models.LeadImportStatusProcessing, reloadImport(t, s.DB, record.ID).Status)
+	assert.Equal(t, map[int]models.ImportRowOutcome{2: ""}, rowOutcomes(t, s.DB, record.ID))
+}
+
+// interruptingWhatsApp stops the import while a phone is being validated
+type interruptingWhatsApp struct {
+	stop context.CancelCauseFunc
+}
+
+func (f interruptingWhatsApp) ValidatePhone(ctx context.Context, _ string, _ int) (bool, error) {
+	f.stop(errShutdown)
+	return false, ctx.Err()
+}
+
+func TestProcessImportLeavesInterruptedRowsPending(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	record := createTestImport(t, s.DB)
+	stageRows(t, s.DB, record.ID, brazilianRow("11987654321"))
+
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+	s.WhatsApp = interruptingWhatsApp{stop: stop}
+	input := StartImportInput{Request: models.ImportRequest{SourceID: 1, AccountID: 1}, CompanyID: 1, UserID: 1}
+	err := s.processImport(ctx, record.ID, input, true)
+	assert.ErrorIs(t, err, errShutdown)
+
+	// The failed validation is not the row's fault
+	reloaded := reloadImport(t, s.DB, record.ID)
+	assert.Equal(t, models.LeadImportStatusProcessing, reloaded.Status)
+	assert.Equal(t, 0, reloaded.TotalErrors)
+	assert.Equal(t, map[int]models.ImportRowOutcome{2: ""}, rowOutcomes(t, s.DB, record.ID))
+}
+
+func TestProcessImportMatchesBothMobileForms(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	require.NoError(t, s.DB.Create(&models.Lead{
+		// Saved before the 9th digit
+		ContactCellphone: "1187654321", ContactCellphoneDialCode: "55", CompanyID: 1, AmigocareMessagingAccountID: 1,
+	}).Error)
+	require.NoError(t, s.DB.Create(&models.Patient{
+		CompanyID: 1, ContactCellphone: "11976543210", ContactCellphoneDialCode: "55",
+	}).Error)
+
+	record := runTestImport(t, s,
+		brazilianRow("11987654321"),
+		brazilianRow("1176543210"),
+		brazilianRow("11912345678"),
+	)
+
+	assert.Equal(t, map[int]models.ImportRowOutcome{
+		2: models.ImportRowOutcomeDuplicateLead,
+		3: models.ImportRowOutcomeDuplicatePatient,
+		4: models.ImportRowOutcomeCreated,
+	}, rowOutcomes(t, s.DB, record.ID))
+	assert.Equal(t, 1, record.TotalCreated)
+	assert.Equal(t, 2, record.TotalExisting)
+}
+
+func TestProcessImportDeduplicatesFileRows(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+
+	record := runTestImport(t, s,
+		brazilianRow("11987654321"),
+		brazilianRow("11912345678"),
+		brazilianRow("11987654321"),
+		models.ParsedRow{Phone: "11987654321", DialCode: "351", CountryCode: "PT"},
+	)
+
+	assert.Equal(t, map[int]models.ImportRowOutcome{
+		2: models.ImportRowOutcomeCreated,
+		3: models.ImportRowOutcomeCreated,
+		4: models.ImportRowOutcomeDuplicateLead,
+		// Another country, another contact
+		5: models.ImportRowOutcomeCreated,
+	}, rowOutcomes(t, s.DB, record.ID))
+	assert.Equal(t, models.LeadImportStatusFinished, record.Status)
+	assert.Equal(t, 3, record.TotalCreated)
+	assert.Equal(t, 1, record.TotalExisting)
+
+	var leads int64
+	require.NoError(t, s.DB.Model(&models.Lead{}).Where("import_id = ?", record.ID).Count(&leads).Error)
+	assert.EqualValues(t, 3, leads)
+}
+
+func TestProcessImportMatchesDuplicatesOnDialCode(t *testing.T) {
+	s := newTestService(t)
+	createTestAccount(t, s.DB)
+	require.NoError(t, s.DB.Create(&[]models.Lead{
+		// Saved before the 9th digit
+		{ContactCellphone: "1187654321", ContactCellphoneDialCode: "55", CompanyID: 1, AmigocareMessagingAccountID: 1},
+		// Same national number in Portugal
+		{ContactCellphone: "11912345678", ContactCellphoneDialCode: "351", CompanyID: 1, AmigocareMessagingAccountID: 1},
+	}).Error)
+	require.NoError(t, s.DB.Create(&[]models.Patient{
+		{CompanyID: 1, ContactCellphone: "11955554444", ContactCellphoneDialCode: "+55"},
+		// A contact stored without a dial code matches any
+		{CompanyID: 1, ContactCellphone: "11933332222", ContactCellphoneDialCode: ""},
+		{CompanyID: 2, ContactCellphone: "11922221111", ContactCellphoneDialCode: "55"},
+	}).Error)
+
+	record := runTestImport(t, s,
+		brazilianRow("11987654321"),
+		brazilianRow("11912345678"),
+		brazilianRow("11955554444"),
+		brazilianRow("11933332222"),
+		brazilianRow("11922221111"),
+	)
+
+	assert.Equal(t, map[int]models.ImportRowOutcome{
+		2: models.ImportRowOutcomeDuplicateLead,
+		3: models.ImportRowOutcomeCreated,
+		4: models.ImportRowOutcomeDuplicatePatient,
+		5: models.ImportRowOutcomeDuplicatePatient,
+		6: models.ImportRowOutcomeCreated,
+	}, rowOutcomes(t, s.DB, record.ID))
+	assert.Equal(t, 2, record.TotalCreated)
+	assert.Equal(t, 3, record.TotalExisting)
+}
+
+func TestPhoneKeyMatches(t *testing.T) {
+	key := phoneKey{DialCode: "55", Phone: "11987654321"}
+	tests := []struct {
+		dialCode string
+		want     bool
+	}{
+		{"55", true},
+		{"+55", true},
+		{"", true},
+		{"351", false},
+		{"+1", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, key.matches(tt.dialCode), tt.dialCode)
+	}
+}
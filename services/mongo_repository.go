@@ -35,7 +35,8 @@ func (r *MongoChatRepository) FindChatsByPhones(ctx context.Context, phones []st
 	var results []struct {
 		ID      bson.ObjectID `bson:"_id"`
 		Contact struct {
-			Phone string `bson:"phone"`
+			Phone    string `bson:"phone"`
+			DialCode string `bson:"dialCode"`
 		} `bson:"contact"`
 		LeadID *int `bson:"leadId"`
 	}
@@ -49,6 +50,7 @@ func (r *MongoChatRepository) FindChatsByPhones(ctx context.Context, phones []st
 		chats = append(chats, Chat{
 			ID:        r.ID.Hex(),
 			Phone:     r.Contact.Phone,
+			DialCode:  r.Contact.DialCode,
 			AccountID: accountID,
 			CompanyID: companyID,
 			LeadID:    r.LeadID,
@@ -106,3 +108,25 @@ func (r *MongoChatRepository) UpdateChatLeadID(ctx context.Context, chatID strin
 
 	return nil
 }
+
+func (r *MongoChatRepository) DeleteChats(ctx context.Context, chatIDs []string) error {
+	if len(chatIDs) == 0 {
+		return nil
+	}
+	coll := r.DB.Collection("chats")
+
+	oids := make([]bson.ObjectID, 0, len(chatIDs))
+	for _, id := range chatIDs {
+		oid, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return fmt.Errorf("invalid chat ID: %w", err)
+		}
+		oids = append(oids, oid)
+	}
+
+	if _, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": oids}}); err != nil {
+		return fmt.Errorf("failed to delete chats: %w", err)
+	}
+
+	return nil
+}
@@ -17,6 +17,10 @@ func (n *NoopChatRepository) UpdateChatLeadID(_ context.Context, _ string, _ int
 	return nil
 }
 
+func (n *NoopChatRepository) DeleteChats(_ context.Context, _ []string) error {
+	return nil
+}
+
 // NoopWhatsAppValidator always returns true.
 type NoopWhatsAppValidator struct{}
 
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"leads-import/database"
+	"leads-import/models"
+	"leads-import/validation"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "test.db"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate(db))
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// testQueueConfig retries right away and never polls during a test
+func testQueueConfig() ImportQueueConfig {
+	return ImportQueueConfig{
+		Workers:       1,
+		LeaseDuration: time.Minute,
+		PollInterval:  time.Hour,
+		MaxAttempts:   3,
+		RetryBackoff:  time.Second,
+		StaleAfter:    time.Minute,
+		DrainTimeout:  time.Second,
+	}
+}
+
+// newTestService returns a service over a test database with no-op dependencies
+func newTestService(t *testing.T) *LeadImportService {
+	t.Helper()
+	db := newTestDB(t)
+	s := &LeadImportService{
+		DB:       db,
+		Chats:    &NoopChatRepository{},
+		WhatsApp: &NoopWhatsAppValidator{},
+		Events:   &NoopEventEmitter{},
+		Cache:    &NoopCacheClearer{},
+		Tracker:  NewImportTracker(),
+		Limits:   UploadLimitsFromEnv(),
+	}
+	s.Queue = NewImportQueue(db, testQueueConfig(), s.runImportJob)
+	return s
+}
+
+// createTestImport stores a PROCESSING import of company 1
+func createTestImport(t *testing.T, db *gorm.DB) models.LeadImport {
+	t.Helper()
+	record := models.LeadImport{
+		Name:      "import " + t.Name(),
+		Status:    models.LeadImportStatusProcessing,
+		CompanyID: 1,
+		CreatorID: 1,
+		SourceID:  1,
+		AccountID: 1,
+	}
+	require.NoError(t, db.Create(&record).Error)
+	return record
+}
+
+func reloadImport(t *testing.T, db *gorm.DB, id int) models.LeadImport {
+	t.Helper()
+	var record models.LeadImport
+	require.NoError(t, db.First(&record, id).Error)
+	return record
+}
+
+func reloadJob(t *testing.T, db *gorm.DB, id int) models.ImportJob {
+	t.Helper()
+	var job models.ImportJob
+	require.NoError(t, db.First(&job, id).Error)
+	return job
+}
+
+// createTestAccount stores the lead source, messaging account and IMPORT channel
+// an import of company 1 needs
+func createTestAccount(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	require.NoError(t, db.Create(&models.LeadSource{ID: 1, Name: "Planilha"}).Error)
+	require.NoError(t, db.Create(&models.MessagingAccount{ID: 1, CompanyID: 1}).Error)
+	require.NoError(t, db.Create(&models.LeadChannel{Name: "IMPORT"}).Error)
+}
+
+func brazilianRow(phone string) models.ParsedRow {
+	return models.ParsedRow{Name: "Lead " + phone, Phone: phone, DialCode: "55", CountryCode: "BR"}
+}
+
+// stageRows stores the staged rows of an import, numbered from 2 as in a file
+func stageRows(t *testing.T, db *gorm.DB, importID int, rows ...models.ParsedRow) {
+	t.Helper()
+	staged := make([]models.ImportRow, len(rows))
+	for i, row := range rows {
+		row.RowNumber = i + 2
+		staged[i] = models.NewImportRow(importID, row)
+	}
+	require.NoError(t, db.Create(&staged).Error)
+}
+
+// rowOutcomes returns the outcome of each staged row of an import by row number,
+// "" for a pending one
+func rowOutcomes(t *testing.T, db *gorm.DB, importID int) map[int]models.ImportRowOutcome {
+	t.Helper()
+	var rows []models.ImportRow
+	require.NoError(t, db.Where("import_id = ?", importID).Find(&rows).Error)
+	outcomes := make(map[int]models.ImportRowOutcome, len(rows))
+	for _, row := range rows {
+		outcomes[row.RowNumber] = ""
+		if row.Outcome != nil {
+			outcomes[row.RowNumber] = *row.Outcome
+		}
+	}
+	return outcomes
+}
+
+// fakeChats finds the existing chats given and records the chats deleted
+type fakeChats struct {
+	NoopChatRepository
+	existing  []Chat
+	deleted   []string
+	deleteErr error
+}
+
+func (f *fakeChats) FindChatsByPhones(_ context.Context, _ []string, _ int, _ int) ([]Chat, error) {
+	return f.existing, nil
+}
+
+func (f *fakeChats) DeleteChats(_ context.Context, chatIDs []string) error {
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	f.deleted = append(f.deleted, chatIDs...)
+	return nil
+}
+
+// fakeWhatsApp rejects the phones listed as not on WhatsApp
+type fakeWhatsApp map[string]bool
+
+func (f fakeWhatsApp) ValidatePhone(_ context.Context, phone string, _ int) (bool, error) {
+	return !f[phone], nil
+}
+
+// openTestCSV opens a parser over a csv file of the given content
+func openTestCSV(t *testing.T, content string) *validation.Parser {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "leads.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+
+	parser, err := validation.OpenFile(file, "leads.csv", validation.ParseOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { parser.Close() })
+	return parser
+}
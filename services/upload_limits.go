@@ -0,0 +1,20 @@
+package services
+
+import "leads-import/validation"
+
+// UploadLimits bounds the resources an import upload may take. See UploadLimitsFromEnv.
+type UploadLimits struct {
+	MaxFileSize     int64 // bytes of the uploaded file
+	MaxUnzipSize    int64 // uncompressed bytes of an xlsx or ods file
+	MaxUnzipXMLSize int64 // uncompressed bytes of an xlsx worksheet kept in memory
+}
+
+// UploadLimitsFromEnv reads IMPORT_MAX_UPLOAD_BYTES, IMPORT_MAX_UNZIP_BYTES and
+// IMPORT_MAX_UNZIP_XML_BYTES, falling back to 20 MB uploads and the parser defaults.
+func UploadLimitsFromEnv() UploadLimits {
+	return UploadLimits{
+		MaxFileSize:     int64(envInt("IMPORT_MAX_UPLOAD_BYTES", 20<<20)),
+		MaxUnzipSize:    int64(envInt("IMPORT_MAX_UNZIP_BYTES", validation.DefaultMaxUnzipSize)),
+		MaxUnzipXMLSize: int64(envInt("IMPORT_MAX_UNZIP_XML_BYTES", validation.DefaultMaxUnzipXMLSize)),
+	}
+}
@@ -0,0 +1,132 @@
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"leads-import/database"
+	"leads-import/internal/testutil"
+	"leads-import/models"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type importEvent struct {
+	Name string
+	Data map[string]interface{}
+}
+
+// readEvents parses a Server-Sent Events stream, skipping comments
+func readEvents(t *testing.T, body string) []importEvent {
+	t.Helper()
+	var events []importEvent
+	for _, block := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		if strings.HasPrefix(block, ":") {
+			continue
+		}
+		lines := strings.Split(block, "\n")
+		require.Len(t, lines, 2, block)
+		require.True(t, strings.HasPrefix(lines[0], "event: "), block)
+		require.True(t, strings.HasPrefix(lines[1], "data: "), block)
+		event := importEvent{Name: strings.TrimPrefix(lines[0], "event: ")}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event.Data))
+		events = append(events, event)
+	}
+	return events
+}
+
+func createImport(t *testing.T, companyID int, status models.LeadImportStatus) models.LeadImport {
+	t.Helper()
+	record := models.LeadImport{Name: t.Name(), Status: status, CompanyID: companyID, CreatorID: 1, SourceID: 1, AccountID: 1, TotalCreated: 2, TotalErrors: 1}
+	require.NoError(t, database.GetDB().Create(&record).Error)
+	return record
+}
+
+func TestImportEventsOfAFinishedImport(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 101, 1)
+	record := createImport(t, 101, models.LeadImportStatusFinished)
+	path := fmt.Sprintf("/imports/%d/events", record.ID)
+
+	resp := testutil.MakeAuthRequest(t, app, "GET", path, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	events := readEvents(t, string(content))
+	require.Len(t, events, 1)
+	assert.Equal(t, "finished", events[0].Name)
+	assert.Equal(t, "FINISHED", events[0].Data["status"])
+	assert.EqualValues(t, 3, events[0].Data["processed_rows"])
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", path, testutil.Token(t, 102, 1), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestImportEventsUntilTheImportFinishes(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 111, 1)
+	record := createImport(t, 111, models.LeadImportStatusProcessing)
+
+	// Finished by a worker of another instance, seen at the next poll
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		database.GetDB().Model(&record).Updates(map[string]interface{}{"status": models.LeadImportStatusFinished, "total_existing": 4})
+	}()
+
+	resp := testutil.MakeAuthRequest(t, app, "GET", fmt.Sprintf("/imports/%d/events", record.ID), token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	events := readEvents(t, string(content))
+	require.Len(t, events, 2)
+	assert.Equal(t, "progress", events[0].Name)
+	assert.Equal(t, "PROCESSING", events[0].Data["status"])
+	assert.Equal(t, "finished", events[1].Name)
+	assert.Equal(t, "FINISHED", events[1].Data["status"])
+	assert.EqualValues(t, 7, events[1].Data["processed_rows"])
+}
+
+func TestImportEventsStopWhenTheClientDisconnects(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 121, 1)
+	record := createImport(t, 121, models.LeadImportStatusProcessing)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/imports/%d/events", ln.Addr(), record.ID), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: progress\n", line)
+	cancel()
+	resp.Body.Close()
+
+	// The stream notices at its next write and returns, which lets the server
+	// shut down although the import is still processing
+	assert.NoError(t, app.ShutdownWithTimeout(10*time.Second))
+}
@@ -0,0 +1,565 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"leads-import/database"
+	"leads-import/handlers"
+	"leads-import/internal/testutil"
+	"leads-import/models"
+	"leads-import/services"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+const leadsCSV = "name,phone,cpf,email,tags\nAna,11987654321,,,\nBia,11912345678,,,\n"
+
+// seedAccount stores a lead source and a messaging account of companyID, and the
+// IMPORT channel, and returns the import data fields using them
+func seedAccount(t *testing.T, companyID int) map[string]interface{} {
+	t.Helper()
+	db := database.GetDB()
+
+	source := models.LeadSource{Name: "Planilha"}
+	require.NoError(t, db.Create(&source).Error)
+	account := models.MessagingAccount{ID: companyID * 100, CompanyID: companyID}
+	require.NoError(t, db.Create(&account).Error)
+	var channels int64
+	require.NoError(t, db.Model(&models.LeadChannel{}).Where("LOWER(name) = 'import'").Count(&channels).Error)
+	if channels == 0 {
+		require.NoError(t, db.Create(&models.LeadChannel{Name: "IMPORT"}).Error)
+	}
+
+	return map[string]interface{}{
+		"name":       t.Name(),
+		"account_id": account.ID,
+		"source_id":  source.ID,
+	}
+}
+
+// uploadLeads builds a POST /import request of a csv file
+func uploadLeads(t *testing.T, token string, data map[string]interface{}, content string, headers map[string]string) *http.Request {
+	t.Helper()
+	encoded, err := json.Marshal(data)
+	require.NoError(t, err)
+	req := testutil.NewUploadRequest(t, "/import", token, map[string]string{"data": string(encoded)}, "leads.csv", []byte(content))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func decode(t *testing.T, resp *http.Response) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	testutil.ParseResponseBody(t, resp, &body)
+	return body
+}
+
+func TestGetImport(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 11, 1)
+	data := seedAccount(t, 11)
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	importID := int(decode(t, resp)["import_id"].(float64))
+
+	// No worker runs in the tests, so the import waits in the queue
+	resp = testutil.MakeAuthRequest(t, app, "GET", fmt.Sprintf("/imports/%d", importID), token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := decode(t, resp)
+	assert.Equal(t, "PROCESSING", body["status"])
+	assert.Equal(t, data["name"], body["name"])
+	assert.EqualValues(t, 0, body["processed_rows"])
+
+	// Another company does not see it
+	resp = testutil.MakeAuthRequest(t, app, "GET", fmt.Sprintf("/imports/%d", importID), testutil.Token(t, 12, 1), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", "/imports/0", token, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestImportRequests(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 21, 1)
+	data := seedAccount(t, 21)
+
+	tests := []struct {
+		name    string
+		token   string
+		content string
+		status  int
+		error   string
+	}{
+		{"missing token", "", leadsCSV, http.StatusUnauthorized, ""},
+		{"invalid rows", token, "name,phone,cpf,email,tags\nAna,123,,,\n", http.StatusBadRequest, "file contains invalid rows"},
+		{"missing phone column", token, "name,email\nAna,ana@example.com\n", http.StatusBadRequest, "file validation failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := uploadLeads(t, tt.token, data, tt.content, nil)
+			if tt.token == "" {
+				req.Header.Del("Authorization")
+			}
+			resp, err := testutil.TestRequest(t, app, req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			if tt.error != "" {
+				assert.Equal(t, tt.error, decode(t, resp)["error"])
+			}
+		})
+	}
+}
+
+func TestListImports(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 61, 1)
+	db := database.GetDB()
+
+	imports := []models.LeadImport{
+		{Name: "Janeiro", Status: models.LeadImportStatusFinished, CompanyID: 61, CreatorID: 1, SourceID: 1, AccountID: 1},
+		{Name: "Fevereiro", Status: models.LeadImportStatusFailed, CompanyID: 61, CreatorID: 2, SourceID: 1, AccountID: 1},
+		{Name: "Março", Status: models.LeadImportStatusFinished, CompanyID: 61, CreatorID: 1, SourceID: 2, AccountID: 1},
+		{Name: "Apagada", Status: models.LeadImportStatusFinished, CompanyID: 61, CreatorID: 1, SourceID: 1, AccountID: 1, IsDeleted: true},
+		{Name: "Outra empresa", Status: models.LeadImportStatusFinished, CompanyID: 62, CreatorID: 1, SourceID: 1, AccountID: 1},
+	}
+	require.NoError(t, db.Create(&imports).Error)
+
+	list := func(query string) ([]string, *int) {
+		t.Helper()
+		resp := testutil.MakeAuthRequest(t, app, "GET", "/imports"+query, token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var page struct {
+			Data       []models.LeadImport `json:"data"`
+			NextCursor *int                `json:"next_cursor"`
+		}
+		testutil.ParseResponseBody(t, resp, &page)
+		names := []string{}
+		for _, record := range page.Data {
+			names = append(names, record.Name)
+		}
+		return names, page.NextCursor
+	}
+
+	names, next := list("")
+	assert.Equal(t, []string{"Março", "Fevereiro", "Janeiro"}, names)
+	assert.Nil(t, next)
+
+	names, _ = list("?status=failed")
+	assert.Equal(t, []string{"Fevereiro"}, names)
+	names, _ = list("?creator_id=1&source_id=1")
+	assert.Equal(t, []string{"Janeiro"}, names)
+	names, _ = list("?name=JAN")
+	assert.Equal(t, []string{"Janeiro"}, names)
+	names, _ = list("?include_deleted=true")
+	assert.Equal(t, []string{"Apagada", "Março", "Fevereiro", "Janeiro"}, names)
+
+	names, next = list("?limit=2")
+	assert.Equal(t, []string{"Março", "Fevereiro"}, names)
+	require.NotNil(t, next)
+	names, next = list(fmt.Sprintf("?limit=2&cursor=%d", *next))
+	assert.Equal(t, []string{"Janeiro"}, names)
+	assert.Nil(t, next)
+
+	resp := testutil.MakeAuthRequest(t, app, "GET", "/imports?status=done", token, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = testutil.MakeAuthRequest(t, app, "GET", "/imports?created_from=yesterday", token, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCancelImport(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 71, 1)
+	data := seedAccount(t, 71)
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	importID := int(decode(t, resp)["import_id"].(float64))
+
+	resp = testutil.MakeAuthRequest(t, app, "POST", fmt.Sprintf("/imports/%d/cancel", importID), testutil.Token(t, 72, 1), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "POST", fmt.Sprintf("/imports/%d/cancel", importID), token, nil)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	body := decode(t, resp)
+	// No worker has picked the import up yet, so it stops right away
+	assert.Equal(t, "CANCELLED", body["status"])
+	assert.Equal(t, true, body["cancel_requested"])
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", fmt.Sprintf("/imports/%d", importID), token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotNil(t, decode(t, resp)["cancel_requested_at"])
+
+	resp = testutil.MakeAuthRequest(t, app, "POST", fmt.Sprintf("/imports/%d/cancel", importID), token, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestDeleteImport(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 81, 1)
+	data := seedAccount(t, 81)
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	importID := int(decode(t, resp)["import_id"].(float64))
+
+	// Still processing: rolling it back must wait
+	resp = testutil.MakeAuthRequest(t, app, "DELETE", fmt.Sprintf("/imports/%d", importID), token, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "POST", fmt.Sprintf("/imports/%d/cancel", importID), token, nil)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "DELETE", fmt.Sprintf("/imports/%d", importID), testutil.Token(t, 82, 1), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "DELETE", fmt.Sprintf("/imports/%d", importID), token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 0, decode(t, resp)["deleted_leads"])
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", "/imports", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, decode(t, resp)["data"])
+}
+
+func TestGetImportReport(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 91, 1)
+	data := seedAccount(t, 91)
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	importID := int(decode(t, resp)["import_id"].(float64))
+	path := fmt.Sprintf("/imports/%d/report", importID)
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", path, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), fmt.Sprintf("import-%d-report.csv", importID))
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "name,phone,cpf,email,tags,status,reason,lead_id\n"+
+		"Ana,11987654321,,,,pending,,\n"+
+		"Bia,11912345678,,,,pending,,\n", string(content))
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", path+"?format=xlsx", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), ".xlsx")
+	f, err := excelize.OpenReader(resp.Body)
+	require.NoError(t, err)
+	defer f.Close()
+	sheetRows, err := f.GetRows(f.GetSheetName(0))
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"name", "phone", "cpf", "email", "tags", "status", "reason", "lead_id"},
+		{"Ana", "11987654321", "", "", "", "pending"},
+		{"Bia", "11912345678", "", "", "", "pending"},
+	}, sheetRows)
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", path+"?format=pdf", token, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = testutil.MakeAuthRequest(t, app, "GET", path, testutil.Token(t, 92, 1), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestImportReadsRequirePermission(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer denied.Close()
+	t.Setenv("AMIGO_API_URL", denied.URL)
+
+	for _, path := range []string{"/imports/1", "/imports/1/report", "/imports/1/events"} {
+		resp := testutil.MakeAuthRequest(t, app, "GET", path, testutil.Token(t, 93, 1), nil)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, path)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 31, 1)
+	data := seedAccount(t, 31)
+	data["dry_run"] = true
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV+"Caio,123,,,\n", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := decode(t, resp)
+	assert.Equal(t, true, body["dry_run"])
+	preview := body["preview"].(map[string]interface{})
+	assert.EqualValues(t, 3, preview["total_rows"])
+	assert.EqualValues(t, 2, preview["to_create"])
+	assert.EqualValues(t, 1, preview["invalid"])
+	assert.Len(t, body["errors"], 1)
+
+	// Nothing was written
+	resp = testutil.MakeAuthRequest(t, app, "GET", "/imports", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, decode(t, resp)["data"])
+}
+
+func TestImportIdempotencyKey(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 41, 1)
+	data := seedAccount(t, 41)
+	key := map[string]string{"Idempotency-Key": "upload-1"}
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, key))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Idempotent-Replayed"))
+	importID := decode(t, resp)["import_id"]
+
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, key))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, importID, decode(t, resp)["import_id"])
+
+	// Same key, another file
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, "name,phone,cpf,email,tags\nCaio,11955554444,,,\n", key))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", "/imports", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, decode(t, resp)["data"], 1)
+}
+
+func TestImportSkipInvalidRows(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 131, 1)
+	data := seedAccount(t, 131)
+	data["skip_invalid_rows"] = true
+
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV+"Caio,123,,,\n,11955554444,,,\n", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := decode(t, resp)
+	assert.EqualValues(t, 2, body["total_invalid"])
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", fmt.Sprintf("/imports/%d", int(body["import_id"].(float64))), token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, 2, decode(t, resp)["total_invalid"])
+
+	// Nothing left to import
+	data["name"] = "only invalid rows"
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, "name,phone,cpf,email,tags\nCaio,123,,,\n", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "file has no valid rows", decode(t, resp)["error"])
+}
+
+func TestImportRowErrors(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 141, 1)
+	data := seedAccount(t, 141)
+
+	content := "name,phone,cpf,email,tags\n"
+	for i := 0; i < 120; i++ {
+		content += fmt.Sprintf("Lead %d,123,,not-an-email,\n", i)
+	}
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	body := decode(t, resp)
+	assert.EqualValues(t, 240, body["total_row_errors"])
+	assert.Len(t, body["errors"], 100)
+	summary := body["error_summary"].([]interface{})
+	require.Len(t, summary, 2)
+	first := summary[0].(map[string]interface{})
+	assert.Equal(t, "phone", first["column"])
+	assert.EqualValues(t, 120, first["count"])
+}
+
+func TestImportColumnMapping(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 151, 1)
+	data := seedAccount(t, 151)
+	data["dry_run"] = true
+	data["column_mapping"] = map[string]string{"Telefone": "phone", "Cliente": "name"}
+
+	content := "Cliente,Observação,Telefone\nAna,VIP,11987654321\n"
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	preview := decode(t, resp)["preview"].(map[string]interface{})
+	assert.EqualValues(t, 1, preview["total_rows"])
+	assert.EqualValues(t, 0, preview["invalid"])
+
+	// Without a mapping the columns are detected from the header
+	delete(data, "column_mapping")
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]interface{}{"Cliente": "name", "Telefone": "phone"}, decode(t, resp)["column_mapping"])
+
+	data["column_mapping"] = map[string]string{"Telefone": "phone"}
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "column_mapping: field 'name' must be mapped to a column", decode(t, resp)["details"])
+}
+
+func TestCustomFields(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 161, 1)
+
+	field := map[string]interface{}{"name": "plano", "label": "Plano", "type": "enum", "options": []string{"Basic", "Gold"}}
+	resp := testutil.MakeAuthRequest(t, app, "POST", "/custom-fields", token, field)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	fieldID := int(decode(t, resp)["id"].(float64))
+
+	resp = testutil.MakeAuthRequest(t, app, "POST", "/custom-fields", token, field)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp = testutil.MakeAuthRequest(t, app, "POST", "/custom-fields", token, map[string]interface{}{"name": "nota", "type": "color"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "GET", "/custom-fields", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Data []models.CustomField `json:"data"`
+	}
+	testutil.ParseResponseBody(t, resp, &list)
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "plano", list.Data[0].Name)
+	assert.Equal(t, []string{"Basic", "Gold"}, list.Data[0].Options)
+
+	// The field column is detected by its label and its values validated
+	data := seedAccount(t, 161)
+	data["dry_run"] = true
+	content := "nome,telefone,plano\nAna,11987654321,gold\nBia,11912345678,silver\n"
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := decode(t, resp)
+	assert.Equal(t, "plano", body["column_mapping"].(map[string]interface{})["plano"])
+	rowError := body["errors"].([]interface{})[0].(map[string]interface{})
+	assert.EqualValues(t, 3, rowError["row"])
+	assert.Equal(t, "plano must be one of: Basic, Gold", rowError["message"])
+
+	// Another company has its own fields
+	resp = testutil.MakeAuthRequest(t, app, "DELETE", fmt.Sprintf("/custom-fields/%d", fieldID), testutil.Token(t, 162, 1), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = testutil.MakeAuthRequest(t, app, "DELETE", fmt.Sprintf("/custom-fields/%d", fieldID), token, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = testutil.MakeAuthRequest(t, app, "DELETE", fmt.Sprintf("/custom-fields/%d", fieldID), token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// The name is free again
+	resp = testutil.MakeAuthRequest(t, app, "POST", "/custom-fields", token, field)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestImportUploadLimits(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 171, 1)
+	data := seedAccount(t, 171)
+
+	importService := services.GetImportService()
+	limits := importService.Limits
+	defer func() { importService.Limits = limits }()
+
+	importService.Limits.MaxFileSize = 32
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, leadsCSV, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, "upload too large: the file must be at most 32 bytes", decode(t, resp)["error"])
+
+	// A spreadsheet that unzips over the limit
+	importService.Limits = limits
+	importService.Limits.MaxUnzipSize = 1 << 10
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]any{"name", "phone"})
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+	f.Close()
+	encoded, err := json.Marshal(data)
+	require.NoError(t, err)
+	req := testutil.NewUploadRequest(t, "/import", token, map[string]string{"data": string(encoded)}, "leads.xlsx", buf.Bytes())
+	resp, err = testutil.TestRequest(t, app, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Contains(t, decode(t, resp)["details"], "its uncompressed content exceeds 1 KB")
+}
+
+func TestErrorHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	// What the server answers once a body exceeds its BodyLimit
+	app.Post("/import", func(c fiber.Ctx) error {
+		return fiber.ErrRequestEntityTooLarge
+	})
+
+	resp, err := testutil.TestRequest(t, app, httptest.NewRequest("POST", "/import", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, "upload too large: the file must be at most 20 MB", decode(t, resp)["error"])
+
+	resp, err = testutil.TestRequest(t, app, httptest.NewRequest("GET", "/missing", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "Not Found", decode(t, resp)["error"])
+}
+
+func TestImportNumberTypePolicy(t *testing.T) {
+	app := testutil.SetupTestApp(t)
+	defer testutil.CleanupTestApp(t)
+	token := testutil.Token(t, 181, 1)
+	data := seedAccount(t, 181)
+	content := "name,phone\nAna,11987654321\nClínica,1134567890\n"
+
+	data["number_type_policy"] = "never"
+	resp, err := testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "number_type_policy must be reject, warn or allow", decode(t, resp)["error"])
+
+	// Landlines are imported with a warning by default
+	delete(data, "number_type_policy")
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := decode(t, resp)
+	assert.EqualValues(t, 1, body["total_warnings"])
+	warning := body["warnings"].([]interface{})[0].(map[string]interface{})
+	assert.EqualValues(t, 3, warning["row"])
+	assert.Equal(t, "phone is a landline and can't receive WhatsApp messages", warning["message"])
+
+	data["name"] = "landlines rejected"
+	data["number_type_policy"] = "reject"
+	resp, err = testutil.TestRequest(t, app, uploadLeads(t, token, data, content, nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.EqualValues(t, 1, decode(t, resp)["total_row_errors"])
+}
@@ -0,0 +1,12 @@
+package e2e
+
+import (
+	"os"
+	"testing"
+
+	"leads-import/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.RunTests(m))
+}
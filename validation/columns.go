@@ -0,0 +1,182 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"leads-import/models"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Lead fields a file column can be mapped to
+const (
+	FieldName  = "name"
+	FieldPhone = "phone"
+	FieldCPF   = "cpf"
+	FieldEmail = "email"
+	FieldTags  = "tags"
+	// FieldCountry is the region of a local-format phone of the row
+	FieldCountry = "country"
+)
+
+// leadFields lists the lead fields in the order of the default file header
+var leadFields = []string{FieldName, FieldPhone, FieldCPF, FieldEmail, FieldTags, FieldCountry}
+
+// requiredFields must be mapped to a file column
+var requiredFields = []string{FieldName, FieldPhone}
+
+// columnLayout holds the file column index of each mapped lead field
+type columnLayout map[string]int
+
+// cell returns the trimmed value of field in row, or "" if the field is not mapped
+// or the row is too short.
+func (l columnLayout) cell(row []string, field string) string {
+	i, ok := l[field]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// headerAliases lists the headers recognised for each lead field when the import
+// has no column mapping, in their normalizeHeader form.
+var headerAliases = map[string][]string{
+	FieldName:    {"name", "nome", "nomecompleto", "fullname", "cliente", "nomedocliente", "contato", "paciente"},
+	FieldPhone:   {"phone", "telefone", "tel", "fone", "celular", "whatsapp", "whats", "zap", "mobile", "phonenumber", "numero", "telefonecelular"},
+	FieldCPF:     {"cpf", "cpfcnpj", "documento", "document"},
+	FieldEmail:   {"email", "mail", "correioeletronico"},
+	FieldTags:    {"tags", "tag", "etiquetas", "etiqueta", "marcadores"},
+	FieldCountry: {"country", "countrycode", "pais", "codigodopais", "regiao", "region"},
+}
+
+// resolveColumns finds the file column of each lead field and custom field and
+// returns the mapping that was used. A mapping goes from file header to lead field
+// or custom field name; headers are matched case-insensitively and unmapped
+// columns are ignored. Without a mapping the columns are detected from the
+// header, see detectColumns.
+func resolveColumns(header []string, mapping map[string]string, customFields []models.CustomField) (columnLayout, map[string]string, error) {
+	if len(mapping) == 0 {
+		return detectColumns(header, customFields)
+	}
+
+	headerIndex := make(map[string]int, len(header))
+	for i, col := range header {
+		key := strings.ToLower(strings.TrimSpace(col))
+		if _, ok := headerIndex[key]; ok {
+			// Ambiguous if mapped, reported below
+			headerIndex[key] = -1
+			continue
+		}
+		headerIndex[key] = i
+	}
+
+	layout := make(columnLayout, len(mapping))
+	for col, field := range mapping {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isLeadField(field) && findCustomField(customFields, field) == nil {
+			return nil, nil, fmt.Errorf("column_mapping: unknown field '%s' for column '%s' (use %s or a custom field)", field, col, strings.Join(leadFields, ", "))
+		}
+		if _, ok := layout[field]; ok {
+			return nil, nil, fmt.Errorf("column_mapping: field '%s' is mapped more than once", field)
+		}
+
+		i, ok := headerIndex[strings.ToLower(strings.TrimSpace(col))]
+		if !ok {
+			return nil, nil, fmt.Errorf("column_mapping: column '%s' not found in the file header", col)
+		}
+		if i < 0 {
+			return nil, nil, fmt.Errorf("column_mapping: column '%s' appears more than once in the file header", col)
+		}
+		layout[field] = i
+	}
+
+	if err := checkRequiredFields(layout, "column_mapping: field '%s' must be mapped to a column"); err != nil {
+		return nil, nil, err
+	}
+
+	return layout, mapping, nil
+}
+
+// detectColumns maps the file columns whose header is a known alias of a lead
+// field, or the name or label of a custom field, in any order and ignoring case,
+// accents and punctuation. When several columns match the same field the first
+// one is used.
+func detectColumns(header []string, customFields []models.CustomField) (columnLayout, map[string]string, error) {
+	layout := make(columnLayout)
+	mapping := make(map[string]string)
+	for i, col := range header {
+		field := aliasField(normalizeHeader(col), customFields)
+		if field == "" {
+			continue
+		}
+		if _, ok := layout[field]; ok {
+			continue
+		}
+		layout[field] = i
+		mapping[col] = field
+	}
+
+	err := checkRequiredFields(layout, "could not find a '%s' column in the file header: rename it or send a column_mapping")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return layout, mapping, nil
+}
+
+func aliasField(header string, customFields []models.CustomField) string {
+	for _, field := range leadFields {
+		for _, alias := range headerAliases[field] {
+			if alias == header {
+				return field
+			}
+		}
+	}
+	for _, cf := range customFields {
+		if header == normalizeHeader(cf.Name) || (cf.Label != "" && header == normalizeHeader(cf.Label)) {
+			return cf.Name
+		}
+	}
+	return ""
+}
+
+func checkRequiredFields(layout columnLayout, format string) error {
+	for _, field := range requiredFields {
+		if _, ok := layout[field]; !ok {
+			return fmt.Errorf(format, field)
+		}
+	}
+	return nil
+}
+
+func isLeadField(field string) bool {
+	for _, f := range leadFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func findCustomField(customFields []models.CustomField, name string) *models.CustomField {
+	for i := range customFields {
+		if customFields[i].Name == name {
+			return &customFields[i]
+		}
+	}
+	return nil
+}
+
+// normalizeHeader lowercases a header and drops accents, spaces and punctuation,
+// so "E-mail" becomes "email" and "Nome Completo" becomes "nomecompleto".
+func normalizeHeader(col string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(col)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
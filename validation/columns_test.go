@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectColumns(t *testing.T) {
+	customFields := []models.CustomField{
+		{Name: "plano", Label: "Plano de Saúde", Type: models.CustomFieldTypeText},
+	}
+
+	tests := []struct {
+		name    string
+		header  []string
+		layout  columnLayout
+		mapping map[string]string
+	}{
+		{
+			name:    "default header",
+			header:  []string{"name", "phone", "cpf", "email", "tags"},
+			layout:  columnLayout{FieldName: 0, FieldPhone: 1, FieldCPF: 2, FieldEmail: 3, FieldTags: 4},
+			mapping: map[string]string{"name": FieldName, "phone": FieldPhone, "cpf": FieldCPF, "email": FieldEmail, "tags": FieldTags},
+		},
+		{
+			name:    "portuguese aliases in any order",
+			header:  []string{"Telefone", "E-mail", "Nome Completo", "Etiquetas"},
+			layout:  columnLayout{FieldPhone: 0, FieldEmail: 1, FieldName: 2, FieldTags: 3},
+			mapping: map[string]string{"Telefone": FieldPhone, "E-mail": FieldEmail, "Nome Completo": FieldName, "Etiquetas": FieldTags},
+		},
+		{
+			name:    "unknown columns are ignored",
+			header:  []string{"Observação", "WhatsApp", "Cliente"},
+			layout:  columnLayout{FieldPhone: 1, FieldName: 2},
+			mapping: map[string]string{"WhatsApp": FieldPhone, "Cliente": FieldName},
+		},
+		{
+			name:    "first matching column wins",
+			header:  []string{"nome", "celular", "telefone"},
+			layout:  columnLayout{FieldName: 0, FieldPhone: 1},
+			mapping: map[string]string{"nome": FieldName, "celular": FieldPhone},
+		},
+		{
+			name:    "custom field by label",
+			header:  []string{"nome", "fone", "PLANO DE SAUDE"},
+			layout:  columnLayout{FieldName: 0, FieldPhone: 1, "plano": 2},
+			mapping: map[string]string{"nome": FieldName, "fone": FieldPhone, "PLANO DE SAUDE": "plano"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, mapping, err := resolveColumns(tt.header, nil, customFields)
+			require.NoError(t, err)
+			assert.Equal(t, tt.layout, layout)
+			assert.Equal(t, tt.mapping, mapping)
+		})
+	}
+}
+
+func TestDetectColumnsMissingRequired(t *testing.T) {
+	_, _, err := detectColumns([]string{"nome", "email"}, nil)
+	assert.EqualError(t, err, "could not find a 'phone' column in the file header: rename it or send a column_mapping")
+}
+
+func TestResolveColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		mapping map[string]string
+		layout  columnLayout
+	}{
+		{
+			name:    "mapping in any order",
+			header:  []string{"Telefone", "E-mail", "Nome"},
+			mapping: map[string]string{"telefone": "phone", "E-MAIL": "Email", "Nome": "name"},
+			layout:  columnLayout{FieldPhone: 0, FieldEmail: 1, FieldName: 2},
+		},
+		{
+			name:    "unmapped columns are ignored",
+			header:  []string{"Observação", "WhatsApp", "Cliente"},
+			mapping: map[string]string{"WhatsApp": "phone", "Cliente": "name"},
+			layout:  columnLayout{FieldPhone: 1, FieldName: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, mapping, err := resolveColumns(tt.header, tt.mapping, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.layout, layout)
+			assert.Equal(t, tt.mapping, mapping)
+		})
+	}
+}
+
+func TestResolveColumnsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		mapping map[string]string
+		err     string
+	}{
+		{
+			name:    "unknown field",
+			header:  []string{"Cliente", "Contato", "Idade"},
+			mapping: map[string]string{"Cliente": "name", "Contato": "phone", "Idade": "idade"},
+			err:     "column_mapping: unknown field 'idade' for column 'Idade' (use name, phone, cpf, email, tags, country or a custom field)",
+		},
+		{
+			name:    "field mapped twice",
+			header:  []string{"Cliente", "Contato", "Celular"},
+			mapping: map[string]string{"Contato": "phone", "Celular": "phone"},
+			err:     "column_mapping: field 'phone' is mapped more than once",
+		},
+		{
+			name:    "column not in header",
+			header:  []string{"Cliente", "Contato"},
+			mapping: map[string]string{"Cliente": "name", "Telefone": "phone"},
+			err:     "column_mapping: column 'Telefone' not found in the file header",
+		},
+		{
+			name:    "ambiguous column",
+			header:  []string{"Cliente", "Contato", "contato"},
+			mapping: map[string]string{"Cliente": "name", "Contato": "phone"},
+			err:     "column_mapping: column 'Contato' appears more than once in the file header",
+		},
+		{
+			name:    "required field not mapped",
+			header:  []string{"Cliente", "Contato"},
+			mapping: map[string]string{"Contato": "phone"},
+			err:     "column_mapping: field 'name' must be mapped to a column",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, _, err := resolveColumns(tt.header, tt.mapping, nil)
+			assert.EqualError(t, err, tt.err)
+			assert.Nil(t, layout)
+		})
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	tests := map[string]string{
+		"E-mail":           "email",
+		"Nome Completo":    "nomecompleto",
+		"  Código do País": "codigodopais",
+		"TELEFONE_1":       "telefone1",
+	}
+	for header, want := range tests {
+		assert.Equal(t, want, normalizeHeader(header), header)
+	}
+}
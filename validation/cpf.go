@@ -51,3 +51,17 @@ func ValidateCPF(raw string) (string, error) {
 
 	return digits, nil
 }
+
+// restoreCPFZeros puts back the leading zeros a spreadsheet drops when a CPF is
+// stored as a number. Only bare digit strings are padded.
+func restoreCPFZeros(cpf string) string {
+	if len(cpf) < 9 || len(cpf) >= 11 {
+		return cpf
+	}
+	for _, c := range cpf {
+		if c < '0' || c > '9' {
+			return cpf
+		}
+	}
+	return strings.Repeat("0", 11-len(cpf)) + cpf
+}
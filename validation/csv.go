@@ -0,0 +1,161 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"mime/multipart"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// csvSniffSize is how much of a CSV file is read ahead to detect its byte
+// order mark and delimiter
+const csvSniffSize = 64 * 1024
+
+// csvDelimiters are the delimiters parseCSV recognises, preferred in this order
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+func parseCSV(file multipart.File, _ ParseOptions) (RowSource, error) {
+	reader, err := newCSVReader(file)
+	if err != nil {
+		return nil, err
+	}
+	return csvRows{reader}, nil
+}
+
+func parseTSV(file multipart.File, _ ParseOptions) (RowSource, error) {
+	reader, err := newCSVReader(file)
+	if err != nil {
+		return nil, err
+	}
+	reader.Comma = '\t'
+	return csvRows{reader}, nil
+}
+
+// csvRows reads a CSV file one record at a time
+type csvRows struct {
+	reader *csv.Reader
+}
+
+func (r csvRows) Next() ([]string, error) {
+	return r.reader.Read()
+}
+
+func (r csvRows) Close() error {
+	return nil
+}
+
+// newCSVReader prepares a csv.Reader for files exported by spreadsheet tools:
+// it strips a byte order mark, transcodes UTF-16 and Windows-1252 (Latin-1) to
+// UTF-8 and detects the delimiter from the header line. Windows-1252 is only
+// known once an invalid UTF-8 byte is read, which can be anywhere in the file.
+func newCSVReader(r io.Reader) (*csv.Reader, error) {
+	br := bufio.NewReaderSize(r, csvSniffSize)
+	sample, err := br.Peek(csvSniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	var input io.Reader
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		br.Discard(len(utf8BOM))
+		sample = sample[len(utf8BOM):]
+		input = br
+	case bytes.HasPrefix(sample, utf16LEBOM), bytes.HasPrefix(sample, utf16BEBOM):
+		// The decoder consumes the BOM and picks the byte order from it
+		charset := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+		input = transform.NewReader(br, charset.NewDecoder())
+		if decoded, err := charset.NewDecoder().Bytes(sample[:len(sample)&^1]); err == nil {
+			sample = decoded
+		}
+	default:
+		input = transform.NewReader(br, &utf8OrWindows1252{latin1: charmap.Windows1252.NewDecoder()})
+	}
+
+	reader := csv.NewReader(input)
+	reader.Comma = sniffDelimiter(sample)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	return reader, nil
+}
+
+// utf8OrWindows1252 passes UTF-8 through and decodes the rest of the input as
+// Windows-1252 from the first byte that is not valid UTF-8.
+type utf8OrWindows1252 struct {
+	latin1   transform.Transformer
+	switched bool
+}
+
+func (t *utf8OrWindows1252) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if !t.switched {
+		valid := 0
+		for valid < len(src) {
+			r, size := utf8.DecodeRune(src[valid:])
+			if r == utf8.RuneError && size <= 1 {
+				if !atEOF && !utf8.FullRune(src[valid:]) {
+					// A rune cut by the buffer, wait for the rest
+					err = transform.ErrShortSrc
+					break
+				}
+				t.switched = true
+				break
+			}
+			valid += size
+		}
+		n := copy(dst, src[:valid])
+		if n < valid {
+			return n, n, transform.ErrShortDst
+		}
+		if !t.switched {
+			return n, n, err
+		}
+		nDst, nSrc = n, n
+	}
+	d, s, err := t.latin1.Transform(dst[nDst:], src[nSrc:], atEOF)
+	return nDst + d, nSrc + s, err
+}
+
+func (t *utf8OrWindows1252) Reset() {
+	t.switched = false
+	t.latin1.Reset()
+}
+
+// sniffDelimiter picks the candidate delimiter found most often in the header
+// line, outside quotes. It falls back to a comma.
+func sniffDelimiter(sample []byte) rune {
+	if i := bytes.IndexAny(sample, "\r\n"); i >= 0 {
+		sample = sample[:i]
+	}
+
+	counts := make(map[rune]int, len(csvDelimiters))
+	inQuotes := false
+	for _, r := range string(sample) {
+		if r == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if !inQuotes {
+			counts[r]++
+		}
+	}
+
+	best := csvDelimiters[0]
+	for _, d := range csvDelimiters[1:] {
+		if counts[d] > counts[best] {
+			best = d
+		}
+	}
+	return best
+}
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{"comma", "name,phone,email\nAna,11987654321,a@b.com", ','},
+		{"semicolon", "name;phone;email\nAna;11987654321;a@b.com", ';'},
+		{"tab", "name\tphone\temail", '\t'},
+		{"pipe", "name|phone|email", '|'},
+		{"quoted commas ignored", `"nome, completo";"telefone, celular";email`, ';'},
+		{"only the header line counts", "name;phone\nAna, Maria, Jo;11987654321", ';'},
+		{"single column falls back to comma", "phone\n11987654321", ','},
+		{"tie prefers comma", "a,b;c", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sniffDelimiter([]byte(tt.sample)))
+		})
+	}
+}
+
+func TestNewCSVReader(t *testing.T) {
+	const content = "nome;telefone\nJoão Conceição;11987654321\n"
+	want := [][]string{{"nome", "telefone"}, {"João Conceição", "11987654321"}}
+
+	windows1252, err := charmap.Windows1252.NewEncoder().String(content)
+	require.NoError(t, err)
+	utf16LE, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(content)
+	require.NoError(t, err)
+	utf16BE, err := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder().String(content)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"utf-8", content},
+		{"utf-8 with bom", "\xEF\xBB\xBF" + content},
+		{"windows-1252", windows1252},
+		{"utf-16le with bom", utf16LE},
+		{"utf-16be with bom", utf16BE},
+		{"crlf line endings", strings.ReplaceAll(content, "\n", "\r\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := newCSVReader(bytes.NewReader([]byte(tt.input)))
+			require.NoError(t, err)
+
+			var rows [][]string
+			for {
+				row, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				rows = append(rows, row)
+			}
+			assert.Equal(t, want, rows)
+		})
+	}
+}
+
+func TestNewCSVReaderRaggedRows(t *testing.T) {
+	reader, err := newCSVReader(strings.NewReader("name,phone,email\nAna,11987654321\n"))
+	require.NoError(t, err)
+
+	_, err = reader.Read()
+	require.NoError(t, err)
+	row, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ana", "11987654321"}, row)
+}
+
+func TestNewCSVReaderLatin1AtTheEnd(t *testing.T) {
+	// The only Windows-1252 character is in the last bytes of the file
+	reader, err := newCSVReader(strings.NewReader("name,phone\nAna,11987654321\nJo\xE3o,1"))
+	require.NoError(t, err)
+
+	rows, err := reader.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "João", rows[2][0])
+}
+
+func TestNewCSVReaderDetectsTheCharsetPastTheSample(t *testing.T) {
+	// ASCII rows fill the sample, the first accented byte comes after it
+	head := "name,phone\n" + strings.Repeat("Ana,11987654321\n", csvSniffSize/16+1)
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Windows-1252", head + "Jo\xE3o,11912345678\n", "João"},
+		// Enough accents for a rune to straddle the read buffers
+		{"UTF-8", head + strings.Repeat("ção", 3000) + ",11912345678\n", strings.Repeat("ção", 3000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := newCSVReader(strings.NewReader(tt.input))
+			require.NoError(t, err)
+
+			rows, err := reader.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rows[len(rows)-1][0])
+		})
+	}
+}
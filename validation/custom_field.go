@@ -0,0 +1,153 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"leads-import/models"
+)
+
+// dateLayouts are the accepted formats of date custom fields, ISO first
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "2/1/2006", "02-01-2006", "02.01.2006"}
+
+var customFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var booleanValues = map[string]bool{
+	"true": true, "false": false,
+	"yes": true, "no": false,
+	"sim": true, "nao": false,
+	"s": true, "n": false,
+	"y": true,
+	"1": true, "0": false,
+}
+
+// ParseCustomValue validates a file cell against a custom field and returns its
+// typed value: a string for text, enum and date (as YYYY-MM-DD) fields, a float64
+// for numbers and a bool for booleans. raw must not be empty.
+func ParseCustomValue(field models.CustomField, raw string) (interface{}, error) {
+	switch field.Type {
+	case models.CustomFieldTypeText:
+		if field.MaxLength != nil && len([]rune(raw)) > *field.MaxLength {
+			return nil, fmt.Errorf("%s must be at most %d characters", field.Name, *field.MaxLength)
+		}
+		return raw, nil
+
+	case models.CustomFieldTypeNumber:
+		value, err := parseNumber(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number, like 1234.56 or 1.234,56", field.Name)
+		}
+		if field.Min != nil && value < *field.Min {
+			return nil, fmt.Errorf("%s must be at least %v", field.Name, *field.Min)
+		}
+		if field.Max != nil && value > *field.Max {
+			return nil, fmt.Errorf("%s must be at most %v", field.Name, *field.Max)
+		}
+		return value, nil
+
+	case models.CustomFieldTypeDate:
+		for _, layout := range dateLayouts {
+			if date, err := time.Parse(layout, raw); err == nil {
+				return date.Format("2006-01-02"), nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD or DD/MM/YYYY)", field.Name)
+
+	case models.CustomFieldTypeBoolean:
+		value, ok := booleanValues[normalizeHeader(raw)]
+		if !ok {
+			return nil, fmt.Errorf("%s must be yes or no", field.Name)
+		}
+		return value, nil
+
+	case models.CustomFieldTypeEnum:
+		for _, option := range field.Options {
+			if strings.EqualFold(option, raw) {
+				return option, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be one of: %s", field.Name, strings.Join(field.Options, ", "))
+	}
+
+	return nil, fmt.Errorf("%s has an unknown type '%s'", field.Name, field.Type)
+}
+
+// parseNumber accepts 1234.56, 1,234.56 and the Brazilian 1.234,56. A comma is
+// the decimal separator only after the last dot; a value mixing separators any
+// other way is rejected instead of guessed.
+func parseNumber(raw string) (float64, error) {
+	lastComma, lastDot := strings.LastIndex(raw, ","), strings.LastIndex(raw, ".")
+	switch {
+	case lastComma < 0:
+	case lastComma > lastDot && strings.Count(raw, ",") == 1 && thousandsGrouped(raw[:lastComma], "."):
+		raw = strings.ReplaceAll(raw[:lastComma], ".", "") + "." + raw[lastComma+1:]
+	case lastComma < lastDot && strings.Count(raw, ".") == 1 && thousandsGrouped(raw[:lastDot], ","):
+		raw = strings.ReplaceAll(raw, ",", "")
+	default:
+		return 0, fmt.Errorf("ambiguous number %q", raw)
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// thousandsGrouped reports whether sep splits the integer part of a number in
+// groups of 3 digits, as thousands separators do
+func thousandsGrouped(integer string, sep string) bool {
+	groups := strings.Split(integer, sep)
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateCustomField checks a custom field definition
+func ValidateCustomField(field models.CustomField) error {
+	if !customFieldName.MatchString(field.Name) {
+		return fmt.Errorf("name must be 1 to 64 lowercase letters, digits or underscores, starting with a letter")
+	}
+	if isLeadField(field.Name) {
+		return fmt.Errorf("name '%s' is reserved for a lead field", field.Name)
+	}
+	if len(field.Label) > 255 {
+		return fmt.Errorf("label must be at most 255 characters")
+	}
+	if !field.Type.IsValid() {
+		return fmt.Errorf("type must be text, number, date, boolean or enum")
+	}
+
+	if field.MaxLength != nil && (field.Type != models.CustomFieldTypeText || *field.MaxLength <= 0) {
+		return fmt.Errorf("max_length must be positive and is only allowed on text fields")
+	}
+	if (field.Min != nil || field.Max != nil) && field.Type != models.CustomFieldTypeNumber {
+		return fmt.Errorf("min and max are only allowed on number fields")
+	}
+	if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+		return fmt.Errorf("min must not be greater than max")
+	}
+
+	if field.Type != models.CustomFieldTypeEnum {
+		if len(field.Options) > 0 {
+			return fmt.Errorf("options are only allowed on enum fields")
+		}
+		return nil
+	}
+	if len(field.Options) == 0 || len(field.Options) > 100 {
+		return fmt.Errorf("enum fields must have 1 to 100 options")
+	}
+	seen := make(map[string]bool, len(field.Options))
+	for _, option := range field.Options {
+		key := strings.ToLower(strings.TrimSpace(option))
+		if key == "" || len(option) > 255 {
+			return fmt.Errorf("options must be 1 to 255 characters")
+		}
+		if seen[key] {
+			return fmt.Errorf("option '%s' is repeated", option)
+		}
+		seen[key] = true
+	}
+	return nil
+}
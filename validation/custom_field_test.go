@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want float64
+	}{
+		{"1234.56", 1234.56},
+		{"1234", 1234},
+		{"-3.5", -3.5},
+		{"1,234.56", 1234.56},
+		{"1,234,567.8", 1234567.8},
+		{"1.234,56", 1234.56},
+		{"1.234.567,89", 1234567.89},
+		{"1,5", 1.5},
+		{"-3,5", -3.5},
+	}
+	for _, tt := range tests {
+		value, err := parseNumber(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, value, tt.raw)
+	}
+}
+
+func TestParseNumberAmbiguous(t *testing.T) {
+	for _, raw := range []string{"1.2,5", "1,2.5", "1,2,3", "1,234,56", "1.234.56", "12,34.5", "abc", ""} {
+		_, err := parseNumber(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestParseCustomValue(t *testing.T) {
+	maxLength, min, max := 5, 0.0, 100.0
+	tests := []struct {
+		name  string
+		field models.CustomField
+		raw   string
+		want  interface{}
+		err   string
+	}{
+		{"text", models.CustomField{Name: "obs", Type: models.CustomFieldTypeText}, "olá", "olá", ""},
+		{"text too long", models.CustomField{Name: "obs", Type: models.CustomFieldTypeText, MaxLength: &maxLength}, "abcdef", nil, "obs must be at most 5 characters"},
+		{"number", models.CustomField{Name: "score", Type: models.CustomFieldTypeNumber, Min: &min, Max: &max}, "99,5", 99.5, ""},
+		{"number over max", models.CustomField{Name: "score", Type: models.CustomFieldTypeNumber, Max: &max}, "1.000,00", nil, "score must be at most 100"},
+		{"ambiguous number", models.CustomField{Name: "score", Type: models.CustomFieldTypeNumber}, "1,2.5", nil, "score must be a number, like 1234.56 or 1.234,56"},
+		{"iso date", models.CustomField{Name: "birth", Type: models.CustomFieldTypeDate}, "1990-02-01", "1990-02-01", ""},
+		{"brazilian date", models.CustomField{Name: "birth", Type: models.CustomFieldTypeDate}, "01/02/1990", "1990-02-01", ""},
+		{"invalid date", models.CustomField{Name: "birth", Type: models.CustomFieldTypeDate}, "1990-13-01", nil, "birth must be a date (YYYY-MM-DD or DD/MM/YYYY)"},
+		{"boolean", models.CustomField{Name: "vip", Type: models.CustomFieldTypeBoolean}, "Não", false, ""},
+		{"enum", models.CustomField{Name: "plan", Type: models.CustomFieldTypeEnum, Options: []string{"Basic", "Gold"}}, "gold", "Gold", ""},
+		{"enum unknown", models.CustomField{Name: "plan", Type: models.CustomFieldTypeEnum, Options: []string{"Basic", "Gold"}}, "silver", nil, "plan must be one of: Basic, Gold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := ParseCustomValue(tt.field, tt.raw)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
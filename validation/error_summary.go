@@ -0,0 +1,36 @@
+package validation
+
+// maxSampleRows is the number of row numbers kept as examples for each error group
+const maxSampleRows = 10
+
+// ErrorSummary groups the row errors sharing the same column and message
+type ErrorSummary struct {
+	Column     string `json:"column"`
+	Message    string `json:"message"`
+	Count      int    `json:"count"`
+	SampleRows []int  `json:"sample_rows"`
+}
+
+// SummarizeErrors groups row errors by column and message, in order of first
+// appearance, keeping the first row numbers of each group as samples.
+func SummarizeErrors(rowErrors []RowError) []ErrorSummary {
+	type key struct{ column, message string }
+
+	index := make(map[key]int)
+	var summary []ErrorSummary
+	for _, e := range rowErrors {
+		k := key{e.Column, e.Message}
+		i, ok := index[k]
+		if !ok {
+			i = len(summary)
+			index[k] = i
+			summary = append(summary, ErrorSummary{Column: e.Column, Message: e.Message})
+		}
+
+		summary[i].Count++
+		if len(summary[i].SampleRows) < maxSampleRows {
+			summary[i].SampleRows = append(summary[i].SampleRows, e.Row)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeErrors(t *testing.T) {
+	rowErrors := []RowError{
+		{Row: 2, Column: "phone", Message: "invalid phone number"},
+		{Row: 2, Column: "email", Message: "invalid email"},
+		{Row: 3, Column: "phone", Message: "invalid phone number"},
+		{Row: 4, Column: "phone", Message: "phone is required"},
+		{Row: 5, Column: "email", Message: "invalid email"},
+	}
+
+	assert.Equal(t, []ErrorSummary{
+		{Column: "phone", Message: "invalid phone number", Count: 2, SampleRows: []int{2, 3}},
+		{Column: "email", Message: "invalid email", Count: 2, SampleRows: []int{2, 5}},
+		{Column: "phone", Message: "phone is required", Count: 1, SampleRows: []int{4}},
+	}, SummarizeErrors(rowErrors))
+}
+
+func TestSummarizeErrorsSampleRows(t *testing.T) {
+	var rowErrors []RowError
+	for row := 2; row < 2+maxSampleRows+5; row++ {
+		rowErrors = append(rowErrors, RowError{Row: row, Column: "name", Message: "name is required"})
+	}
+
+	summary := SummarizeErrors(rowErrors)
+	if assert.Len(t, summary, 1) {
+		assert.Equal(t, maxSampleRows+5, summary[0].Count)
+		assert.Len(t, summary[0].SampleRows, maxSampleRows)
+		assert.Equal(t, 2, summary[0].SampleRows[0])
+	}
+}
+
+func TestSummarizeErrorsEmpty(t *testing.T) {
+	assert.Empty(t, SummarizeErrors(nil))
+}
+
+func TestParseFileCollectsEveryRowError(t *testing.T) {
+	content := "name,phone,cpf,email,tags\n" +
+		",123,111.111.111-11,not-an-email,\n" +
+		"Ana,11987654321,,ana@example.com,\n"
+
+	result, err := ParseFile(newMemFile([]byte(content)), "leads.csv", ParseOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var columns []string
+	for _, e := range result.Errors {
+		assert.Equal(t, 2, e.Row, fmt.Sprint(e))
+		columns = append(columns, e.Column)
+	}
+	assert.Equal(t, []string{"name", "phone", "cpf", "email"}, columns)
+	assert.Len(t, result.Invalid, 1)
+	assert.Len(t, result.Rows, 1)
+}
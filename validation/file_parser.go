@@ -1,7 +1,6 @@
 package validation
 
 import (
-	"encoding/csv"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -9,8 +8,6 @@ import (
 	"strings"
 
 	"leads-import/models"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type RowError struct {
@@ -19,106 +16,258 @@ type RowError struct {
 	Message string `json:"message"`
 }
 
-func ParseFile(file multipart.File, filename string) ([]models.ParsedRow, []RowError, error) {
-	ext := strings.ToLower(filepath.Ext(filename))
+// ParseResult holds the header, the valid rows and the row errors of an import file.
+type ParseResult struct {
+	Header  []string
+	Rows    []models.ParsedRow
+	Invalid []models.InvalidRow
+	Errors  []RowError
+	// Warnings are issues of valid rows, see ParseOptions.NumberTypePolicy
+	Warnings []RowError
+
+	// ColumnMapping is the file header to lead field mapping used, given or detected
+	ColumnMapping map[string]string
+}
 
-	var rawRows [][]string
-	var err error
+// DefaultMaxRows is the data row limit of an import file when ParseOptions has none
+const DefaultMaxRows = 5000
 
-	switch ext {
-	case ".csv":
-		rawRows, err = parseCSV(file)
-	case ".xlsx":
-		rawRows, err = parseExcel(file, filename)
-	default:
-		return nil, nil, fmt.Errorf("unsupported file format: %s (use .csv or .xlsx)", ext)
-	}
+// ParseOptions customizes how an import file is read.
+type ParseOptions struct {
+	// ColumnMapping maps file headers to lead fields, see resolveColumns
+	ColumnMapping map[string]string
+	// CustomFields are the company custom fields extra columns can be imported to
+	CustomFields []models.CustomField
+	// Sheet selects the spreadsheet sheet to import, by name or 1-based position
+	Sheet string
+	// PhoneRegion is the region of local-format phones on rows without a country,
+	// DefaultPhoneRegion if empty
+	PhoneRegion string
+	// NumberTypePolicy makes a phone that is not a mobile number a row error, a
+	// warning (the default) or neither
+	NumberTypePolicy models.NumberTypePolicy
+	// MaxRows is the most data rows a file may have, DefaultMaxRows if zero
+	MaxRows int
+	// MaxUnzipSize is the most uncompressed bytes of an xlsx or ods file,
+	// DefaultMaxUnzipSize if zero
+	MaxUnzipSize int64
+	// MaxUnzipXMLSize is the largest xlsx worksheet kept in memory, bigger ones
+	// are read from a temporary file. DefaultMaxUnzipXMLSize if zero.
+	MaxUnzipXMLSize int64
+}
+
+// Parser reads and validates an import file one row at a time, so a large file
+// is never held in memory as a whole.
+type Parser struct {
+	Header []string
+	// ColumnMapping is the file header to lead field mapping used, given or detected
+	ColumnMapping map[string]string
+	// Warnings of the rows read so far
+	Warnings []RowError
+
+	source   RowSource
+	layout   columnLayout
+	opts     ParseOptions
+	rowNum   int
+	dataRows int
+}
+
+// OpenFile detects the format of an import file, reads its header and resolves
+// its columns. The caller must Close the parser.
+func OpenFile(file multipart.File, filename string, opts ParseOptions) (*Parser, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
 
+	head, err := readHead(file)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
-
-	if len(rawRows) == 0 {
-		return nil, nil, fmt.Errorf("file is empty")
+	format := detectFormat(ext, head)
+	if format == nil {
+		return nil, fmt.Errorf("unsupported file format: %s (use %s)", ext, supportedExtensions())
 	}
 
-	// Validate header
-	header := rawRows[0]
-	if err := validateHeader(header); err != nil {
-		return nil, nil, err
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = DefaultMaxRows
+	}
+	source, err := format.Read(file, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file: %w", err)
 	}
 
-	dataRows := rawRows[1:]
-	if len(dataRows) == 0 {
-		return nil, nil, fmt.Errorf("file must have at least 1 data row")
+	header, err := source.Next()
+	if err == io.EOF {
+		source.Close()
+		return nil, fmt.Errorf("file is empty")
 	}
-	if len(dataRows) > 5000 {
-		return nil, nil, fmt.Errorf("file must have at most 5000 data rows, got %d", len(dataRows))
+	if err != nil {
+		source.Close()
+		return nil, fmt.Errorf("failed to parse file: %w", err)
 	}
 
-	var parsed []models.ParsedRow
-	var errors []RowError
+	// Validate header and column mapping
+	layout, mapping, err := resolveColumns(header, opts.ColumnMapping, opts.CustomFields)
+	if err != nil {
+		source.Close()
+		return nil, err
+	}
 
-	for i, row := range dataRows {
-		rowNum := i + 2 // 1-indexed, skip header
+	return &Parser{Header: header, ColumnMapping: mapping, source: source, layout: layout, opts: opts, rowNum: 1}, nil
+}
 
-		// Pad row to 5 columns
-		for len(row) < 5 {
-			row = append(row, "")
+// Next reads and validates the next data row. An invalid row comes with its
+// errors; io.EOF is returned after the last row. Blank rows are skipped.
+func (p *Parser) Next() (models.ParsedRow, []RowError, error) {
+	for {
+		row, err := p.source.Next()
+		if err == io.EOF {
+			if p.dataRows == 0 {
+				return models.ParsedRow{}, nil, fmt.Errorf("file must have at least 1 data row")
+			}
+			return models.ParsedRow{}, nil, io.EOF
 		}
-
-		name := strings.TrimSpace(row[0])
-		phone := strings.TrimSpace(row[1])
-		cpf := strings.TrimSpace(row[2])
-		email := strings.TrimSpace(row[3])
-		tagsRaw := strings.TrimSpace(row[4])
-
-		// Name: required, max 255
-		if name == "" {
-			errors = append(errors, RowError{Row: rowNum, Column: "name", Message: "name is required"})
-			continue
+		if err != nil {
+			return models.ParsedRow{}, nil, fmt.Errorf("failed to parse file: %w", err)
 		}
-		if len(name) > 255 {
-			errors = append(errors, RowError{Row: rowNum, Column: "name", Message: "name must be at most 255 characters"})
+		p.rowNum++ // 1-indexed, the header is row 1
+		if isBlankRow(row) {
 			continue
 		}
 
-		// Phone: required, must be valid
-		if phone == "" {
-			errors = append(errors, RowError{Row: rowNum, Column: "phone", Message: "phone is required"})
-			continue
+		p.dataRows++
+		if p.dataRows > p.opts.MaxRows {
+			return models.ParsedRow{}, nil, fmt.Errorf("file must have at most %d data rows", p.opts.MaxRows)
+		}
+		parsed, rowErrors, warnings := parseRow(p.rowNum, row, p.layout, p.opts)
+		p.Warnings = append(p.Warnings, warnings...)
+		return parsed, rowErrors, nil
+	}
+}
+
+// Close releases the file reader
+func (p *Parser) Close() error {
+	return p.source.Close()
+}
+
+// ParseFile reads and validates a whole import file at once.
+func ParseFile(file multipart.File, filename string, opts ParseOptions) (*ParseResult, error) {
+	parser, err := OpenFile(file, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+
+	result := &ParseResult{Header: parser.Header, ColumnMapping: parser.ColumnMapping}
+	for {
+		row, rowErrors, err := parser.Next()
+		if err == io.EOF {
+			result.Warnings = parser.Warnings
+			return result, nil
 		}
-		phoneInfo, err := ParsePhone(phone)
 		if err != nil {
-			errors = append(errors, RowError{Row: rowNum, Column: "phone", Message: err.Error()})
+			return nil, err
+		}
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, rowErrors...)
+			result.Invalid = append(result.Invalid, InvalidRow(row, rowErrors))
 			continue
 		}
+		result.Rows = append(result.Rows, row)
+	}
+}
 
-		// CPF: optional, validate if present
-		validCPF := ""
-		if cpf != "" {
-			validCPF, err = ValidateCPF(cpf)
-			if err != nil {
-				errors = append(errors, RowError{Row: rowNum, Column: "cpf", Message: err.Error()})
-				continue
-			}
+// InvalidRow keeps a row that failed validation along with its error messages
+func InvalidRow(row models.ParsedRow, rowErrors []RowError) models.InvalidRow {
+	return models.InvalidRow{
+		RowNumber: row.RowNumber,
+		RawCells:  row.RawCells,
+		Message:   joinRowErrors(rowErrors),
+	}
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRow validates a data row and reports every invalid column, then the
+// warnings of a valid row. The returned row always carries the raw cells.
+func parseRow(rowNum int, row []string, layout columnLayout, opts ParseOptions) (models.ParsedRow, []RowError, []RowError) {
+	result := models.ParsedRow{RowNumber: rowNum, RawCells: append([]string(nil), row...)}
+	var rowErrors, warnings []RowError
+	addError := func(column string, message string) {
+		rowErrors = append(rowErrors, RowError{Row: rowNum, Column: column, Message: message})
+	}
+
+	name := layout.cell(row, FieldName)
+	phone := layout.cell(row, FieldPhone)
+	cpf := restoreCPFZeros(layout.cell(row, FieldCPF))
+	email := layout.cell(row, FieldEmail)
+	tagsRaw := layout.cell(row, FieldTags)
+	country := layout.cell(row, FieldCountry)
+
+	// Name: required, max 255
+	if name == "" {
+		addError(FieldName, "name is required")
+	} else if len(name) > 255 {
+		addError(FieldName, "name must be at most 255 characters")
+	}
+
+	// Country: optional, the region of a local-format phone
+	region, validRegion := opts.PhoneRegion, true
+	if country != "" {
+		if region, validRegion = NormalizeRegion(country); !validRegion {
+			addError(FieldCountry, "invalid country: use a 2-letter code like BR or PT, or a dial code like +351")
 		}
+	}
 
-		// Email: optional, validate if present
-		if email != "" {
-			if err := ValidateEmail(email); err != nil {
-				errors = append(errors, RowError{Row: rowNum, Column: "email", Message: err.Error()})
-				continue
-			}
+	// Phone: required, must be valid in the row region
+	var phoneInfo *PhoneInfo
+	if phone == "" {
+		addError(FieldPhone, "phone is required")
+	} else if validRegion {
+		var err error
+		if phoneInfo, err = ParsePhone(phone, region); err != nil {
+			addError(FieldPhone, err.Error())
 		}
+	}
 
-		// Tags: optional, max 5, max 255 chars total
-		var tagNames []string
-		if tagsRaw != "" {
-			if len(tagsRaw) > 255 {
-				errors = append(errors, RowError{Row: rowNum, Column: "tags", Message: "tags must be at most 255 characters"})
-				continue
-			}
+	// Phone type: landlines and the like can't be WhatsApp contacts
+	if phoneInfo != nil && !phoneInfo.Type.IsMobile() {
+		switch opts.NumberTypePolicy {
+		case models.NumberTypePolicyReject:
+			addError(FieldPhone, phoneTypeMessage(phoneInfo.Type))
+		case models.NumberTypePolicyAllow:
+		default:
+			warnings = append(warnings, RowError{Row: rowNum, Column: FieldPhone, Message: phoneTypeMessage(phoneInfo.Type)})
+		}
+	}
+
+	// CPF: optional, validate if present
+	validCPF := ""
+	if cpf != "" {
+		var err error
+		if validCPF, err = ValidateCPF(cpf); err != nil {
+			addError(FieldCPF, err.Error())
+		}
+	}
+
+	// Email: optional, validate if present
+	if email != "" {
+		if err := ValidateEmail(email); err != nil {
+			addError(FieldEmail, err.Error())
+		}
+	}
+
+	// Tags: optional, max 5, max 255 chars total
+	var tagNames []string
+	if tagsRaw != "" {
+		if len(tagsRaw) > 255 {
+			addError(FieldTags, "tags must be at most 255 characters")
+		} else {
 			parts := strings.Split(tagsRaw, ",")
 			for _, p := range parts {
 				t := strings.TrimSpace(p)
@@ -127,61 +276,56 @@ func ParseFile(file multipart.File, filename string) ([]models.ParsedRow, []RowE
 				}
 			}
 			if len(tagNames) > 5 {
-				errors = append(errors, RowError{Row: rowNum, Column: "tags", Message: "max 5 tags per row"})
-				continue
+				addError(FieldTags, "max 5 tags per row")
 			}
 		}
-
-		parsed = append(parsed, models.ParsedRow{
-			Name:        name,
-			Phone:       phoneInfo.National,
-			CPF:         validCPF,
-			Email:       email,
-			TagNames:    tagNames,
-			DialCode:    phoneInfo.DialCode,
-			CountryCode: phoneInfo.CountryCode,
-		})
 	}
 
-	return parsed, errors, nil
-}
-
-func validateHeader(header []string) error {
-	expected := []string{"name", "phone", "cpf", "email", "tags"}
-	if len(header) != 5 {
-		return fmt.Errorf("file must have exactly 5 columns (name, phone, cpf, email, tags), got %d", len(header))
-	}
-	for i, col := range header {
-		if strings.TrimSpace(strings.ToLower(col)) != expected[i] {
-			return fmt.Errorf("column %d must be '%s', got '%s'", i+1, expected[i], strings.TrimSpace(col))
+	// Custom fields: only the mapped ones
+	var customValues map[string]interface{}
+	for _, cf := range opts.CustomFields {
+		if _, ok := layout[cf.Name]; !ok {
+			continue
+		}
+		raw := layout.cell(row, cf.Name)
+		if raw == "" {
+			if cf.Required {
+				addError(cf.Name, cf.Name+" is required")
+			}
+			continue
+		}
+		value, err := ParseCustomValue(cf, raw)
+		if err != nil {
+			addError(cf.Name, err.Error())
+			continue
+		}
+		if customValues == nil {
+			customValues = make(map[string]interface{})
 		}
+		customValues[cf.Name] = value
 	}
-	return nil
-}
-
-func parseCSV(r io.Reader) ([][]string, error) {
-	reader := csv.NewReader(r)
-	reader.TrimLeadingSpace = true
-	reader.FieldsPerRecord = -1
-	return reader.ReadAll()
-}
 
-func parseExcel(file multipart.File, filename string) ([][]string, error) {
-	f, err := excelize.OpenReader(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open excel file: %w", err)
+	if len(rowErrors) > 0 {
+		return result, rowErrors, nil
 	}
-	defer f.Close()
 
-	sheetName := f.GetSheetName(0)
-	if sheetName == "" {
-		return nil, fmt.Errorf("excel file has no sheets")
-	}
+	result.Name = name
+	result.Phone = phoneInfo.National
+	result.CPF = validCPF
+	result.Email = email
+	result.TagNames = tagNames
+	result.CustomFields = customValues
+	result.DialCode = phoneInfo.DialCode
+	result.CountryCode = phoneInfo.CountryCode
+	result.PhoneRegion = phoneInfo.Region
+	result.PhoneType = phoneInfo.Type
+	return result, nil, warnings
+}
 
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read excel rows: %w", err)
+func joinRowErrors(rowErrors []RowError) string {
+	messages := make([]string, len(rowErrors))
+	for i, e := range rowErrors {
+		messages[i] = e.Column + ": " + e.Message
 	}
-
-	return rows, nil
+	return strings.Join(messages, "; ")
 }
@@ -0,0 +1,147 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+// maxNDJSONLine is the longest line parseNDJSON accepts
+const maxNDJSONLine = 1024 * 1024
+
+// parseJSON reads a JSON array of objects. The keys become the header, in the
+// order they first appear.
+func parseJSON(file multipart.File, _ ParseOptions) ([][]string, error) {
+	decoder := json.NewDecoder(skipBOM(file))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if token != json.Delim('[') {
+		return nil, fmt.Errorf("JSON file must be an array of objects")
+	}
+
+	var table jsonTable
+	for i := 1; decoder.More(); i++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		if err := table.add(raw); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return table.rows(), nil
+}
+
+// parseNDJSON reads one JSON object per line, like parseJSON.
+func parseNDJSON(file multipart.File, _ ParseOptions) ([][]string, error) {
+	scanner := bufio.NewScanner(skipBOM(file))
+	scanner.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+
+	var table jsonTable
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if err := table.add(raw); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return table.rows(), nil
+}
+
+// jsonTable turns JSON objects into rows sharing a header
+type jsonTable struct {
+	header  []string
+	columns map[string]int
+	records [][]string
+}
+
+func (t *jsonTable) add(raw []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("each row must be a JSON object")
+	}
+
+	if t.columns == nil {
+		t.columns = make(map[string]int)
+	}
+	var record []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		key := token.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		i, ok := t.columns[key]
+		if !ok {
+			i = len(t.header)
+			t.columns[key] = i
+			t.header = append(t.header, key)
+		}
+		for len(record) <= i {
+			record = append(record, "")
+		}
+		record[i] = jsonCell(value)
+	}
+
+	t.records = append(t.records, record)
+	return nil
+}
+
+func (t *jsonTable) rows() [][]string {
+	if len(t.records) == 0 {
+		return nil
+	}
+	return append([][]string{t.header}, t.records...)
+}
+
+// jsonCell renders a JSON value as a cell: arrays are joined with commas, like
+// the tags column, and nested objects are kept as JSON.
+func jsonCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		cells := make([]string, len(v))
+		for i, item := range v {
+			cells[i] = jsonCell(item)
+		}
+		return strings.Join(cells, ",")
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// skipBOM drops a UTF-8 byte order mark at the start of r
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(head, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+const (
+	// DefaultMaxUnzipSize is the uncompressed size limit of an xlsx or ods file
+	// when ParseOptions has none
+	DefaultMaxUnzipSize = 256 << 20
+	// DefaultMaxUnzipXMLSize is the largest xlsx worksheet kept in memory when
+	// ParseOptions has no limit, bigger ones are read from a temporary file
+	DefaultMaxUnzipXMLSize = 16 << 20
+)
+
+var (
+	// ErrFileTooLarge rejects a file over a size limit, compressed or not
+	ErrFileTooLarge = errors.New("file is too large")
+	// ErrEncryptedWorkbook rejects a password-protected spreadsheet
+	ErrEncryptedWorkbook = errors.New("password-protected workbooks are not supported, remove the password and upload the file again")
+	// ErrMacroWorkbook rejects a spreadsheet with macros
+	ErrMacroWorkbook = errors.New("macro-enabled workbooks are not accepted, save the file without macros and upload it again")
+)
+
+// openArchive opens an xlsx or ods file as a zip archive. It rejects archives
+// whose uncompressed content exceeds opts.MaxUnzipSize, like zip bombs, and
+// workbooks with macros. The zip reader fails on entries larger than they
+// declare, so the declared sizes can be trusted.
+func openArchive(file multipart.File, opts ParseOptions) (*zip.Reader, error) {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spreadsheet archive: %w", err)
+	}
+
+	limit := opts.maxUnzipSize()
+	var total uint64
+	for _, f := range archive.File {
+		total += f.UncompressedSize64
+		if total > uint64(limit) {
+			return nil, fmt.Errorf("%w: its uncompressed content exceeds %s", ErrFileTooLarge, FormatBytes(limit))
+		}
+		if isMacroPart(f.Name) {
+			return nil, ErrMacroWorkbook
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// isMacroPart reports whether an archive entry holds macros: the VBA project of
+// an xlsm file or a Basic module or script of an ods file. The library indexes
+// LibreOffice writes for an empty Basic library are not macros.
+func isMacroPart(name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "\\", "/"))
+	switch {
+	case strings.HasSuffix(name, "vbaproject.bin"):
+		return true
+	case strings.HasPrefix(name, "scripts/") && !strings.HasSuffix(name, "/"):
+		return true
+	case strings.HasPrefix(name, "basic/"):
+		base := name[strings.LastIndex(name, "/")+1:]
+		return base != "" && base != "script-lc.xml" && base != "script-lb.xml"
+	}
+	return false
+}
+
+// maxManifestSize bounds how much of an ods manifest is read
+const maxManifestSize = 1 << 20
+
+// isEncryptedODS reports whether the manifest of an ods file declares encrypted entries
+func isEncryptedODS(archive *zip.Reader) bool {
+	manifest, err := archive.Open("META-INF/manifest.xml")
+	if err != nil {
+		return false
+	}
+	defer manifest.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(manifest, maxManifestSize))
+	return bytes.Contains(data, []byte("encryption-data"))
+}
+
+func (opts ParseOptions) maxUnzipSize() int64 {
+	if opts.MaxUnzipSize > 0 {
+		return opts.MaxUnzipSize
+	}
+	return DefaultMaxUnzipSize
+}
+
+func (opts ParseOptions) maxUnzipXMLSize() int64 {
+	if opts.MaxUnzipXMLSize > 0 && opts.MaxUnzipXMLSize <= opts.maxUnzipSize() {
+		return opts.MaxUnzipXMLSize
+	}
+	return min(DefaultMaxUnzipXMLSize, opts.maxUnzipSize())
+}
+
+// FormatBytes renders a size limit for error messages, like 20 MB
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+// withEntry copies a zip archive and adds an entry to it
+func withEntry(t *testing.T, archive []byte, name string, content string) []byte {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range reader.File {
+		require.NoError(t, w.Copy(f))
+	}
+	entry, err := w.Create(name)
+	require.NoError(t, err)
+	entry.Write([]byte(content))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseFileUnzipSizeLimit(t *testing.T) {
+	content := xlsxFile(t, func(f *excelize.File) {
+		f.SetSheetRow("Sheet1", "A1", &[]any{"name", "phone", "tags"})
+		// Compresses to almost nothing
+		f.SetSheetRow("Sheet1", "A2", &[]any{"Ana", "11987654321", strings.Repeat("a", 32000)})
+	})
+	require.Less(t, len(content), 16<<10)
+
+	_, err := ParseFile(newMemFile(content), "leads.xlsx", ParseOptions{MaxUnzipSize: 16 << 10})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	assert.ErrorContains(t, err, "its uncompressed content exceeds 16 KB")
+
+	_, err = ParseFile(newMemFile(content), "leads.xlsx", ParseOptions{MaxUnzipSize: 1 << 20})
+	assert.NotErrorIs(t, err, ErrFileTooLarge)
+}
+
+func TestParseFileRejectsMacroWorkbooks(t *testing.T) {
+	xlsx := xlsxFile(t, func(f *excelize.File) {
+		f.SetSheetRow("Sheet1", "A1", &[]any{"name", "phone"})
+	})
+	xlsm := withEntry(t, xlsx, "xl/vbaProject.bin", "vba")
+	_, err := ParseFile(newMemFile(xlsm), "leads.xlsx", ParseOptions{})
+	assert.ErrorIs(t, err, ErrMacroWorkbook)
+	_, err = ParseFile(newMemFile(xlsm), "leads.xlsm", ParseOptions{})
+	assert.ErrorIs(t, err, ErrMacroWorkbook)
+
+	ods := withEntry(t, odsFile(t, odsRow("name", "phone")), "Basic/Standard/Module1.xml", "<script:module/>")
+	_, err = ParseFile(newMemFile(ods), "leads.ods", ParseOptions{})
+	assert.ErrorIs(t, err, ErrMacroWorkbook)
+}
+
+func TestIsMacroPart(t *testing.T) {
+	tests := map[string]bool{
+		"xl/vbaProject.bin":            true,
+		"Basic/Standard/Module1.xml":   true,
+		"Scripts/python/macro.py":      true,
+		"Basic/script-lc.xml":          false,
+		"Basic/Standard/script-lb.xml": false,
+		"Scripts/":                     false,
+		"xl/worksheets/sheet1.xml":     false,
+		"content.xml":                  false,
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, isMacroPart(name), name)
+	}
+}
+
+func TestParseFileRejectsEncryptedWorkbooks(t *testing.T) {
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]any{"name", "phone"})
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf, excelize.Options{Password: "secret"}))
+	f.Close()
+
+	_, err := ParseFile(newMemFile(buf.Bytes()), "leads.xlsx", ParseOptions{})
+	assert.ErrorIs(t, err, ErrEncryptedWorkbook)
+
+	ods := withEntry(t, odsFile(t, odsRow("name", "phone")), "META-INF/manifest.xml",
+		`<manifest:file-entry manifest:full-path="content.xml"><manifest:encryption-data/></manifest:file-entry>`)
+	_, err = ParseFile(newMemFile(ods), "leads.ods", ParseOptions{})
+	assert.ErrorIs(t, err, ErrEncryptedWorkbook)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "20 MB", FormatBytes(20<<20))
+	assert.Equal(t, "16 KB", FormatBytes(16<<10))
+	assert.Equal(t, "1500 bytes", FormatBytes(1500))
+}
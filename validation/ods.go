@@ -0,0 +1,292 @@
+package validation
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+const (
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// maxODSColumns is the widest ODS row read, the column limit of spreadsheet tools
+const maxODSColumns = 16384
+
+// parseODS reads the rows of the selected sheet of an OpenDocument spreadsheet.
+// Numbers, dates and booleans are read from their stored value, other cells as
+// displayed.
+func parseODS(file multipart.File, opts ParseOptions) (RowSource, error) {
+	archive, err := openArchive(file, opts)
+	if err != nil {
+		return nil, err
+	}
+	if isEncryptedODS(archive) {
+		return nil, ErrEncryptedWorkbook
+	}
+
+	names, err := readODSSheetNames(archive)
+	if err != nil {
+		return nil, err
+	}
+	index, err := resolveSheet(names, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := archive.Open("content.xml")
+	if err != nil {
+		return nil, fmt.Errorf("ods file has no content.xml: %w", err)
+	}
+	rows, err := newODSRows(content, index, opts.MaxRows)
+	if err != nil {
+		content.Close()
+		return nil, err
+	}
+	return rows, nil
+}
+
+func readODSSheetNames(archive *zip.Reader) ([]string, error) {
+	content, err := archive.Open("content.xml")
+	if err != nil {
+		return nil, fmt.Errorf("ods file has no content.xml: %w", err)
+	}
+	defer content.Close()
+
+	var names []string
+	decoder := xml.NewDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ods content: %w", err)
+		}
+		if t, ok := token.(xml.StartElement); ok && t.Name.Space == odsTableNS && t.Name.Local == "table" {
+			names = append(names, attr(t, odsTableNS, "name"))
+			if err := decoder.Skip(); err != nil {
+				return nil, fmt.Errorf("failed to read ods content: %w", err)
+			}
+		}
+	}
+}
+
+// odsRows streams the rows of one table of content.xml. Repeated rows and cells
+// are expanded one row at a time, except trailing empty ones that spreadsheet
+// tools write to fill the sheet, so a tiny file repeating a cell millions of
+// times is never held in memory.
+type odsRows struct {
+	content      io.ReadCloser
+	decoder      *xml.Decoder
+	maxEmptyRows int
+	tableDepth   int
+	done         bool
+
+	// emptyRows blank rows are returned before row is returned repeat times
+	emptyRows int
+	row       []string
+	repeat    int
+	// pendingEmpty counts empty rows read but not returned yet, in case a
+	// non-empty one follows
+	pendingEmpty int
+}
+
+// newODSRows skips to the sheet-th table of content.xml. A gap of more than
+// maxEmptyRows empty rows before a data row is an error.
+func newODSRows(content io.ReadCloser, sheet int, maxEmptyRows int) (*odsRows, error) {
+	decoder := xml.NewDecoder(content)
+	for tables := 0; tables < sheet; {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ods content: %w", err)
+		}
+		if t, ok := token.(xml.StartElement); ok && t.Name.Space == odsTableNS && t.Name.Local == "table" {
+			if err := decoder.Skip(); err != nil {
+				return nil, fmt.Errorf("failed to read ods content: %w", err)
+			}
+			tables++
+		}
+	}
+	if maxEmptyRows <= 0 {
+		maxEmptyRows = DefaultMaxRows
+	}
+	return &odsRows{content: content, decoder: decoder, maxEmptyRows: maxEmptyRows}, nil
+}
+
+func (r *odsRows) Next() ([]string, error) {
+	for {
+		if r.emptyRows > 0 {
+			r.emptyRows--
+			return nil, nil
+		}
+		if r.repeat > 0 {
+			r.repeat--
+			return append([]string(nil), r.row...), nil
+		}
+		if r.done {
+			return nil, io.EOF
+		}
+		if err := r.readRow(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *odsRows) Close() error {
+	return r.content.Close()
+}
+
+// readRow reads up to the end of the next table row, or of the table
+func (r *odsRows) readRow() error {
+	var (
+		row        []string
+		emptyCells int
+		rowRepeat  int
+		cellRepeat int
+		cellValue  *string // stored value of a typed cell
+		cellText   strings.Builder
+		inCell     bool
+		paragraphs int
+	)
+
+	for {
+		token, err := r.decoder.Token()
+		if err == io.EOF {
+			r.done = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read ods content: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				r.tableDepth++
+			case r.tableDepth == 0:
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				row = nil
+				emptyCells = 0
+				rowRepeat = repeatAttr(t, "number-rows-repeated")
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = true
+				paragraphs = 0
+				cellText.Reset()
+				cellValue = nil
+				cellRepeat = repeatAttr(t, "number-columns-repeated")
+				if v, ok := odsStoredValue(t); ok {
+					cellValue = &v
+				}
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "p":
+				if paragraphs > 0 {
+					cellText.WriteString("\n")
+				}
+				paragraphs++
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "s":
+				cellText.WriteString(strings.Repeat(" ", min(repeatAttrNS(t, odsTextNS, "c"), maxODSColumns)))
+			case inCell && t.Name.Space == odsTextNS && t.Name.Local == "tab":
+				cellText.WriteString("\t")
+			}
+
+		case xml.CharData:
+			if inCell && paragraphs > 0 {
+				cellText.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				r.tableDepth--
+				if r.tableDepth == 0 {
+					r.done = true
+					return nil
+				}
+			case r.tableDepth == 0:
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = false
+				value := cellText.String()
+				if cellValue != nil {
+					value = *cellValue
+				}
+				if value == "" {
+					emptyCells = addRepeat(emptyCells, cellRepeat, maxODSColumns)
+					continue
+				}
+				if addRepeat(len(row)+emptyCells, cellRepeat, maxODSColumns) > maxODSColumns {
+					return fmt.Errorf("ods rows must have at most %d columns", maxODSColumns)
+				}
+				for ; emptyCells > 0; emptyCells-- {
+					row = append(row, "")
+				}
+				for i := 0; i < cellRepeat; i++ {
+					row = append(row, value)
+				}
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				if len(row) == 0 {
+					r.pendingEmpty = addRepeat(r.pendingEmpty, rowRepeat, r.maxEmptyRows)
+					continue
+				}
+				if r.pendingEmpty > r.maxEmptyRows {
+					return fmt.Errorf("ods sheet must have at most %d consecutive empty rows", r.maxEmptyRows)
+				}
+				r.emptyRows, r.pendingEmpty = r.pendingEmpty, 0
+				r.row, r.repeat = row, rowRepeat
+				return nil
+			}
+		}
+	}
+}
+
+// addRepeat adds a repeat count to n, saturating just above limit
+func addRepeat(n, repeat, limit int) int {
+	if repeat > limit-n {
+		return limit + 1
+	}
+	return n + repeat
+}
+
+// odsStoredValue returns the value a typed cell stores next to its displayed text
+func odsStoredValue(t xml.StartElement) (string, bool) {
+	var value string
+	switch attr(t, odsOfficeNS, "value-type") {
+	case "float", "percentage", "currency":
+		value = plainNumber(attr(t, odsOfficeNS, "value"))
+	case "boolean":
+		value = attr(t, odsOfficeNS, "boolean-value")
+	case "date":
+		// 1990-02-01 or 1990-02-01T10:30:00
+		value = strings.TrimSuffix(attr(t, odsOfficeNS, "date-value"), "T00:00:00")
+		value = strings.Replace(value, "T", " ", 1)
+	}
+	return value, value != ""
+}
+
+func attr(t xml.StartElement, space, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func repeatAttr(t xml.StartElement, local string) int {
+	return repeatAttrNS(t, odsTableNS, local)
+}
+
+// repeatAttrNS reads a repeat count attribute, which defaults to 1
+func repeatAttrNS(t xml.StartElement, space, local string) int {
+	n, err := strconv.Atoi(attr(t, space, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
@@ -3,21 +3,36 @@ package validation
 import (
 	"fmt"
 	"strconv"
+	"strings"
+
+	"leads-import/models"
 
 	"github.com/nyaruka/phonenumbers"
 )
 
+// DefaultPhoneRegion is the region of local-format phone numbers when the
+// import sets none
+const DefaultPhoneRegion = "BR"
+
 type PhoneInfo struct {
 	DialCode    string
 	CountryCode string
 	National    string
+	Region      string // region the number was parsed with
+	Type        models.PhoneType
 }
 
-func ParsePhone(raw string) (*PhoneInfo, error) {
-	num, err := phonenumbers.Parse(raw, "BR")
+// ParsePhone parses a phone number, reading a number without an international
+// prefix as a local number of region, or of DefaultPhoneRegion if empty.
+func ParsePhone(raw string, region string) (*PhoneInfo, error) {
+	if region == "" {
+		region = DefaultPhoneRegion
+	}
+	num, err := phonenumbers.Parse(raw, region)
 	if err != nil {
 		return nil, fmt.Errorf("invalid phone number: %w", err)
 	}
+	num = addNinthDigit(num)
 
 	if !phonenumbers.IsValidNumber(num) {
 		return nil, fmt.Errorf("invalid phone number")
@@ -39,5 +54,89 @@ func ParsePhone(raw string) (*PhoneInfo, error) {
 		DialCode:    dialCode,
 		CountryCode: countryCode,
 		National:    cleaned,
+		Region:      region,
+		Type:        phoneType(phonenumbers.GetNumberType(num)),
 	}, nil
 }
+
+// brazilDialCode is the dial code of the numbers addNinthDigit and PhoneVariants handle
+const brazilDialCode = 55
+
+// addNinthDigit turns a Brazilian mobile number of the legacy 8-digit format into
+// the current one, which has a 9 before the subscriber number in every DDD since
+// 2016. Legacy mobile numbers started with 6 to 9, landlines with 2 to 5. The
+// range decides, as the phone metadata still accepts some legacy numbers.
+func addNinthDigit(num *phonenumbers.PhoneNumber) *phonenumbers.PhoneNumber {
+	if num.GetCountryCode() != brazilDialCode {
+		return num
+	}
+	nsn := phonenumbers.GetNationalSignificantNumber(num)
+	if len(nsn) != 10 || nsn[2] < '6' || nsn[2] > '9' {
+		return num
+	}
+	mobile, err := phonenumbers.Parse("+55"+nsn[:2]+"9"+nsn[2:], "BR")
+	if err != nil || !phonenumbers.IsValidNumber(mobile) {
+		return num
+	}
+	return mobile
+}
+
+// PhoneVariants returns the forms a national phone number may be stored in:
+// itself and, for a Brazilian mobile number, its form with or without the 9th
+// digit, so a contact saved before the change is still found.
+func PhoneVariants(national string, dialCode string) []string {
+	if dialCode != strconv.Itoa(brazilDialCode) {
+		return []string{national}
+	}
+	switch {
+	case len(national) == 11 && national[2] == '9' && national[3] >= '6' && national[3] <= '9':
+		return []string{national, national[:2] + national[3:]}
+	case len(national) == 10 && national[2] >= '6' && national[2] <= '9':
+		return []string{national, national[:2] + "9" + national[2:]}
+	}
+	return []string{national}
+}
+
+func phoneType(t phonenumbers.PhoneNumberType) models.PhoneType {
+	switch t {
+	case phonenumbers.MOBILE:
+		return models.PhoneTypeMobile
+	case phonenumbers.FIXED_LINE:
+		return models.PhoneTypeFixed
+	case phonenumbers.FIXED_LINE_OR_MOBILE:
+		return models.PhoneTypeFixedOrMobile
+	case phonenumbers.VOIP:
+		return models.PhoneTypeVoIP
+	case phonenumbers.TOLL_FREE:
+		return models.PhoneTypeTollFree
+	}
+	return models.PhoneTypeOther
+}
+
+// phoneTypeNames describe the phone types that are not mobile numbers in row messages
+var phoneTypeNames = map[models.PhoneType]string{
+	models.PhoneTypeFixed:    "a landline",
+	models.PhoneTypeVoIP:     "a VoIP number",
+	models.PhoneTypeTollFree: "a toll-free number",
+	models.PhoneTypeOther:    "not a mobile number",
+}
+
+// phoneTypeMessage explains why a phone that is not a mobile number can't be a WhatsApp contact
+func phoneTypeMessage(t models.PhoneType) string {
+	return "phone is " + phoneTypeNames[t] + " and can't receive WhatsApp messages"
+}
+
+// NormalizeRegion turns a country given as a 2-letter region code, like pt, or
+// a dial code, like +351, into the region code phone numbers are parsed with.
+// A dial code shared by several countries gives its main one.
+func NormalizeRegion(value string) (string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if dialCode, err := strconv.Atoi(strings.TrimPrefix(value, "+")); err == nil {
+		region := phonenumbers.GetRegionCodeForCountryCode(dialCode)
+		return region, region != phonenumbers.UNKNOWN_REGION
+	}
+	if phonenumbers.GetCountryCodeForRegion(value) == 0 {
+		return "", false
+	}
+	return value, true
+}
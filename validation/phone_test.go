@@ -0,0 +1,190 @@
+package validation
+
+import (
+	"testing"
+
+	"leads-import/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePhoneNinthDigit(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		national string
+		phone    models.PhoneType
+	}{
+		{"current mobile", "(11) 98765-4321", "11987654321", models.PhoneTypeMobile},
+		{"legacy mobile starting with 9", "(11) 9876-5432", "11998765432", models.PhoneTypeMobile},
+		{"legacy mobile starting with 8", "(11) 8765-4321", "11987654321", models.PhoneTypeMobile},
+		{"legacy mobile starting with 7", "(11) 7654-3210", "11976543210", models.PhoneTypeMobile},
+		{"legacy mobile starting with 6", "(61) 6543-2109", "61965432109", models.PhoneTypeMobile},
+		{"legacy mobile with dial code", "+55 21 8765-4321", "21987654321", models.PhoneTypeMobile},
+		{"landline", "(11) 3456-7890", "1134567890", models.PhoneTypeFixed},
+		{"landline starting with 2", "(21) 2555-1234", "2125551234", models.PhoneTypeFixed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParsePhone(tt.raw, "BR")
+			require.NoError(t, err)
+			assert.Equal(t, tt.national, info.National)
+			assert.Equal(t, "55", info.DialCode)
+			assert.Equal(t, tt.phone, info.Type)
+		})
+	}
+}
+
+func TestParsePhoneRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		region   string
+		dialCode string
+		national string
+	}{
+		{"default region", "11987654321", "", "55", "11987654321"},
+		{"portuguese mobile", "912 345 678", "PT", "351", "912345678"},
+		{"international prefix wins", "+351 912 345 678", "BR", "351", "912345678"},
+		{"us number", "(212) 555-1234", "US", "1", "2125551234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParsePhone(tt.raw, tt.region)
+			require.NoError(t, err)
+			assert.Equal(t, tt.dialCode, info.DialCode)
+			assert.Equal(t, tt.national, info.National)
+		})
+	}
+}
+
+func TestParsePhoneType(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want models.PhoneType
+	}{
+		{"(11) 98765-4321", models.PhoneTypeMobile},
+		{"(11) 3456-7890", models.PhoneTypeFixed},
+		{"+44 56 1234 5678", models.PhoneTypeVoIP},
+		{"0800 123 4567", models.PhoneTypeTollFree},
+		{"+1 212 555 1234", models.PhoneTypeFixedOrMobile},
+	}
+	for _, tt := range tests {
+		info, err := ParsePhone(tt.raw, "BR")
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, info.Type, tt.raw)
+	}
+}
+
+func TestParseRowNumberTypePolicy(t *testing.T) {
+	const (
+		landline = "phone is a landline and can't receive WhatsApp messages"
+		voip     = "phone is a VoIP number and can't receive WhatsApp messages"
+	)
+	tests := []struct {
+		name    string
+		phone   string
+		policy  models.NumberTypePolicy
+		err     string
+		warning string
+	}{
+		{"mobile rejected", "11987654321", models.NumberTypePolicyReject, "", ""},
+		{"mobile warned", "11987654321", models.NumberTypePolicyWarn, "", ""},
+		{"mobile allowed", "11987654321", models.NumberTypePolicyAllow, "", ""},
+		{"landline rejected", "1134567890", models.NumberTypePolicyReject, landline, ""},
+		{"landline warned", "1134567890", models.NumberTypePolicyWarn, "", landline},
+		{"landline warned by default", "1134567890", "", "", landline},
+		{"landline allowed", "1134567890", models.NumberTypePolicyAllow, "", ""},
+		{"voip rejected", "+44 56 1234 5678", models.NumberTypePolicyReject, voip, ""},
+		{"voip warned", "+44 56 1234 5678", models.NumberTypePolicyWarn, "", voip},
+		{"voip allowed", "+44 56 1234 5678", models.NumberTypePolicyAllow, "", ""},
+	}
+	layout := columnLayout{FieldName: 0, FieldPhone: 1}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, rowErrors, warnings := parseRow(2, []string{"Ana", tt.phone}, layout, ParseOptions{NumberTypePolicy: tt.policy})
+			if tt.err != "" {
+				assert.Equal(t, []RowError{{Row: 2, Column: FieldPhone, Message: tt.err}}, rowErrors)
+				assert.Empty(t, warnings)
+				return
+			}
+			require.Empty(t, rowErrors)
+			assert.NotEmpty(t, row.PhoneType)
+			if tt.warning != "" {
+				assert.Equal(t, []RowError{{Row: 2, Column: FieldPhone, Message: tt.warning}}, warnings)
+			} else {
+				assert.Empty(t, warnings)
+			}
+		})
+	}
+}
+
+func TestParsePhoneInvalid(t *testing.T) {
+	for _, raw := range []string{"abc", "123", "(11) 1234-5678"} {
+		_, err := ParsePhone(raw, "BR")
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestPhoneVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		national string
+		dialCode string
+		want     []string
+	}{
+		{"current mobile", "11987654321", "55", []string{"11987654321", "1187654321"}},
+		{"legacy mobile", "1187654321", "55", []string{"1187654321", "11987654321"}},
+		{"landline", "1134567890", "55", []string{"1134567890"}},
+		{"mobile 9 followed by a landline digit", "11934567890", "55", []string{"11934567890"}},
+		{"other country", "2125551234", "1", []string{"2125551234"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PhoneVariants(tt.national, tt.dialCode))
+		})
+	}
+}
+
+func TestNormalizeRegion(t *testing.T) {
+	tests := []struct {
+		value  string
+		region string
+		ok     bool
+	}{
+		{"br", "BR", true},
+		{" PT ", "PT", true},
+		{"+351", "PT", true},
+		{"55", "BR", true},
+		{"1", "US", true},
+		{"XX", "", false},
+		{"+999", "", false},
+	}
+	for _, tt := range tests {
+		region, ok := NormalizeRegion(tt.value)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		if tt.ok {
+			assert.Equal(t, tt.region, region, tt.value)
+		}
+	}
+}
+
+func TestParseFileCountryColumn(t *testing.T) {
+	content := "nome,telefone,país\n" +
+		"Ana,912 345 678,PT\n" +
+		"Bia,912 345 678,\n" +
+		"Caio,11987654321,+55\n" +
+		"Duda,11987654321,XX\n"
+
+	result, err := ParseFile(newMemFile([]byte(content)), "leads.csv", ParseOptions{PhoneRegion: "PT"})
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 3)
+	// Rows without a country use the import region
+	for i, dialCode := range []string{"351", "351", "55"} {
+		assert.Equal(t, dialCode, result.Rows[i].DialCode, result.Rows[i].Name)
+	}
+	assert.Equal(t, "BR", result.Rows[2].PhoneRegion)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, RowError{Row: 5, Column: FieldCountry, Message: "invalid country: use a 2-letter code like BR or PT, or a dial code like +351"}, result.Errors[0])
+}
@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// RowSource yields the raw rows of an import file one at a time, header first.
+// Every format yields cells as text so the row validation does not depend on the
+// format.
+type RowSource interface {
+	// Next returns the next row, or io.EOF after the last one
+	Next() ([]string, error)
+	Close() error
+}
+
+// RowReader opens the selected rows of an import file
+type RowReader func(file multipart.File, opts ParseOptions) (RowSource, error)
+
+// rowSlice is the RowSource of a format whose library loads the whole sheet
+type rowSlice [][]string
+
+func (r *rowSlice) Next() ([]string, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	row := (*r)[0]
+	*r = (*r)[1:]
+	return row, nil
+}
+
+func (r *rowSlice) Close() error {
+	return nil
+}
+
+// readAll adapts a reader returning every row at once to a RowReader
+func readAll(read func(file multipart.File, opts ParseOptions) ([][]string, error)) RowReader {
+	return func(file multipart.File, opts ParseOptions) (RowSource, error) {
+		rows, err := read(file, opts)
+		if err != nil {
+			return nil, err
+		}
+		source := rowSlice(rows)
+		return &source, nil
+	}
+}
+
+// FileFormat is an import file format ParseFile can read
+type FileFormat struct {
+	Name       string
+	Extensions []string // lowercase, with the leading dot
+	// Sniff reports whether the first bytes of a file are in this format. It lets
+	// a renamed or extensionless file be read; nil if the content can't tell.
+	Sniff func(head []byte) bool
+	Read  RowReader
+}
+
+// sniffSize is how much of a file is read to detect its format
+const sniffSize = 512
+
+// fileFormats is the reader registry, in the order formats are sniffed
+var fileFormats []FileFormat
+
+// RegisterFormat adds a file format to the ones ParseFile accepts. A format
+// registered later takes over the extensions of an earlier one.
+func RegisterFormat(format FileFormat) {
+	fileFormats = append(fileFormats, format)
+}
+
+func init() {
+	RegisterFormat(FileFormat{Name: "xlsx", Extensions: []string{".xlsx"}, Sniff: isXLSX, Read: parseExcel})
+	RegisterFormat(FileFormat{Name: "ods", Extensions: []string{".ods"}, Sniff: isODS, Read: parseODS})
+	RegisterFormat(FileFormat{Name: "xls", Extensions: []string{".xls"}, Sniff: isXLS, Read: readAll(parseXLS)})
+	RegisterFormat(FileFormat{Name: "json", Extensions: []string{".json"}, Sniff: isJSONArray, Read: readAll(parseJSON)})
+	RegisterFormat(FileFormat{Name: "ndjson", Extensions: []string{".ndjson", ".jsonl"}, Sniff: isNDJSON, Read: readAll(parseNDJSON)})
+	RegisterFormat(FileFormat{Name: "tsv", Extensions: []string{".tsv", ".tab"}, Read: parseTSV})
+	RegisterFormat(FileFormat{Name: "csv", Extensions: []string{".csv", ".txt"}, Sniff: isText, Read: parseCSV})
+}
+
+// detectFormat picks the format of a file from its extension, unless the content
+// says otherwise, then from its content alone.
+func detectFormat(ext string, head []byte) *FileFormat {
+	var byExt *FileFormat
+	for i := range fileFormats {
+		for _, e := range fileFormats[i].Extensions {
+			if e == ext {
+				byExt = &fileFormats[i]
+			}
+		}
+	}
+	if byExt != nil && (byExt.Sniff == nil || byExt.Sniff(head)) {
+		return byExt
+	}
+
+	for i := range fileFormats {
+		if fileFormats[i].Sniff != nil && fileFormats[i].Sniff(head) {
+			return &fileFormats[i]
+		}
+	}
+	return byExt
+}
+
+// readHead reads the first bytes of a file and rewinds it.
+func readHead(file multipart.File) ([]byte, error) {
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+func supportedExtensions() string {
+	var exts []string
+	for _, f := range fileFormats {
+		exts = append(exts, f.Extensions...)
+	}
+	return strings.Join(exts, ", ")
+}
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+// An ODS file starts with a stored "mimetype" entry holding its content type
+func isODS(head []byte) bool {
+	return bytes.HasPrefix(head, zipMagic) &&
+		bytes.Contains(head, []byte("mimetypeapplication/vnd.oasis.opendocument.spreadsheet"))
+}
+
+func isXLSX(head []byte) bool {
+	return bytes.HasPrefix(head, zipMagic) && !isODS(head)
+}
+
+func isXLS(head []byte) bool {
+	return bytes.HasPrefix(head, oleMagic)
+}
+
+func isJSONArray(head []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(trimBOM(head), " \t\r\n"), []byte("["))
+}
+
+func isNDJSON(head []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(trimBOM(head), " \t\r\n"), []byte("{"))
+}
+
+func isText(head []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(head), "text/")
+}
+
+func trimBOM(head []byte) []byte {
+	return bytes.TrimPrefix(head, utf8BOM)
+}
@@ -0,0 +1,201 @@
+package validation
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// odsFile builds an ODS file whose first sheet has the given table rows markup
+func odsFile(t *testing.T, rows string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	mimetype, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	mimetype.Write([]byte("application/vnd.oasis.opendocument.spreadsheet"))
+	content, err := w.Create("content.xml")
+	require.NoError(t, err)
+	content.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+		`<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+		`<office:body><office:spreadsheet><table:table table:name="Leads">` + rows +
+		`</table:table></office:spreadsheet></office:body></office:document-content>`))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func odsRow(cells ...string) string {
+	var b strings.Builder
+	b.WriteString("<table:table-row>")
+	for _, cell := range cells {
+		b.WriteString("<table:table-cell><text:p>" + cell + "</text:p></table:table-cell>")
+	}
+	b.WriteString("</table:table-row>")
+	return b.String()
+}
+
+func TestDetectFormat(t *testing.T) {
+	ods := odsFile(t, "")
+	tests := []struct {
+		name string
+		ext  string
+		head []byte
+		want string
+	}{
+		{"csv by extension", ".csv", []byte("name,phone\n"), "csv"},
+		{"tsv by extension", ".tsv", []byte("name\tphone\n"), "tsv"},
+		{"xlsx by extension", ".xlsx", []byte("PK\x03\x04rest"), "xlsx"},
+		{"ods by content", ".ods", ods, "ods"},
+		{"ods renamed to xlsx", ".xlsx", ods, "ods"},
+		{"xls by content", "", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "xls"},
+		{"json array", ".json", []byte("  [{\"name\": \"Ana\"}]"), "json"},
+		{"ndjson by content", "", []byte("{\"name\": \"Ana\"}\n"), "ndjson"},
+		{"jsonl extension", ".jsonl", []byte("{\"name\": \"Ana\"}\n"), "ndjson"},
+		{"extensionless text", "", []byte("name;phone\n"), "csv"},
+		{"csv named txt", ".txt", []byte("name,phone\n"), "csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format := detectFormat(tt.ext, tt.head)
+			require.NotNil(t, format)
+			assert.Equal(t, tt.want, format.Name)
+		})
+	}
+
+	assert.Nil(t, detectFormat(".pdf", []byte("%PDF-1.4\x00\x01\x02")))
+}
+
+func TestRegisterFormatTakesOverExtensions(t *testing.T) {
+	saved := fileFormats
+	defer func() { fileFormats = saved }()
+
+	RegisterFormat(FileFormat{
+		Name:       "pipe",
+		Extensions: []string{".txt"},
+		Read: func(file multipart.File, opts ParseOptions) (RowSource, error) {
+			content, err := io.ReadAll(file)
+			if err != nil {
+				return nil, err
+			}
+			var rows rowSlice
+			for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				rows = append(rows, strings.Split(line, "|"))
+			}
+			return &rows, nil
+		},
+	})
+
+	assert.Equal(t, "pipe", detectFormat(".txt", []byte("name|phone")).Name)
+	assert.Equal(t, "csv", detectFormat(".csv", []byte("name,phone")).Name)
+
+	result, err := ParseFile(newMemFile([]byte("name|phone\nAna|11987654321")), "leads.txt", ParseOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "11987654321", result.Rows[0].Phone)
+}
+
+func TestParseFileFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+	}{
+		{"csv", "leads.csv", []byte("name;phone;tags\nAna;11987654321;\"vip,novo\"\n")},
+		{"tsv", "leads.tsv", []byte("name\tphone\ttags\nAna\t11987654321\tvip,novo\n")},
+		{"json", "leads.json", []byte(`[{"name": "Ana", "phone": 11987654321, "tags": ["vip", "novo"]}]`)},
+		{"ndjson", "leads.ndjson", []byte("{\"name\": \"Ana\", \"phone\": \"11987654321\", \"tags\": \"vip,novo\"}\n\n")},
+		{"ods", "leads.ods", odsFile(t, odsRow("name", "phone", "tags")+odsRow("Ana", "11987654321", "vip,novo"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFile(newMemFile(tt.content), tt.filename, ParseOptions{})
+			require.NoError(t, err)
+			require.Empty(t, result.Errors)
+			require.Len(t, result.Rows, 1)
+			row := result.Rows[0]
+			assert.Equal(t, 2, row.RowNumber)
+			assert.Equal(t, "Ana", row.Name)
+			assert.Equal(t, "11987654321", row.Phone)
+			assert.Equal(t, []string{"vip", "novo"}, row.TagNames)
+		})
+	}
+}
+
+func TestParseFileUnsupportedFormat(t *testing.T) {
+	_, err := ParseFile(newMemFile([]byte("%PDF-1.4\x00\x01\x02")), "leads.pdf", ParseOptions{})
+	assert.ErrorContains(t, err, "unsupported file format: .pdf")
+}
+
+func TestODSRepeatedCells(t *testing.T) {
+	rows := odsRow("name", "phone", "email") +
+		`<table:table-row table:number-rows-repeated="2"><table:table-cell/></table:table-row>` +
+		`<table:table-row table:number-rows-repeated="2">` +
+		`<table:table-cell><text:p>Ana</text:p></table:table-cell>` +
+		`<table:table-cell table:number-columns-repeated="2"><text:p>11987654321</text:p></table:table-cell>` +
+		`</table:table-row>` +
+		// Spreadsheet tools fill the sheet with trailing empty rows and cells
+		`<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>`
+
+	source, err := parseODS(newMemFile(odsFile(t, rows)), ParseOptions{MaxRows: 10})
+	require.NoError(t, err)
+	defer source.Close()
+
+	var got [][]string
+	for {
+		row, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, row)
+	}
+	assert.Equal(t, [][]string{
+		{"name", "phone", "email"},
+		nil,
+		nil,
+		{"Ana", "11987654321", "11987654321"},
+		{"Ana", "11987654321", "11987654321"},
+	}, got)
+}
+
+func TestODSRepeatLimits(t *testing.T) {
+	header := odsRow("name", "phone")
+	tests := []struct {
+		name string
+		rows string
+		err  string
+	}{
+		{
+			name: "repeated rows stop at the row limit",
+			rows: header + `<table:table-row table:number-rows-repeated="1000000000">` +
+				`<table:table-cell><text:p>Ana</text:p></table:table-cell>` +
+				`<table:table-cell><text:p>11987654321</text:p></table:table-cell></table:table-row>`,
+			err: "file must have at most 100 data rows",
+		},
+		{
+			name: "repeated cells stop at the column limit",
+			rows: header + `<table:table-row><table:table-cell table:number-columns-repeated="1000000000"><text:p>x</text:p></table:table-cell></table:table-row>`,
+			err:  "ods rows must have at most 16384 columns",
+		},
+		{
+			name: "empty rows before a data row stop at the row limit",
+			rows: header + `<table:table-row table:number-rows-repeated="1000000000"><table:table-cell/></table:table-row>` +
+				odsRow("Ana", "11987654321"),
+			err: "ods sheet must have at most 100 consecutive empty rows",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile(newMemFile(odsFile(t, tt.rows)), "leads.ods", ParseOptions{MaxRows: 100})
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
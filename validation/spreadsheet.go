@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// resolveSheet finds the sheet to import among names: by name, ignoring case,
+// or by its 1-based position. An empty sheet selects the first one.
+func resolveSheet(names []string, sheet string) (int, error) {
+	if len(names) == 0 {
+		return 0, fmt.Errorf("file has no sheets")
+	}
+	sheet = strings.TrimSpace(sheet)
+	if sheet == "" {
+		return 0, nil
+	}
+
+	for i, name := range names {
+		if strings.EqualFold(strings.TrimSpace(name), sheet) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(sheet); err == nil && n >= 1 && n <= len(names) {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf("sheet '%s' not found (available: %s)", sheet, strings.Join(names, ", "))
+}
+
+// isDateFormat reports whether a spreadsheet number format displays a date.
+// Built-in formats are identified by id, custom ones by the y and d tokens of
+// their format code.
+func isDateFormat(id int, format string) bool {
+	if format == "" {
+		return id >= 14 && id <= 17 || id == 22 || id >= 27 && id <= 36 || id >= 50 && id <= 58
+	}
+
+	// Drop literal text, which may contain any letter
+	var code strings.Builder
+	inQuotes, inBrackets, escaped := false, false, false
+	for _, r := range format {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case r == '[':
+			inBrackets = true
+		case r == ']':
+			inBrackets = false
+		case inBrackets:
+		default:
+			code.WriteRune(r)
+		}
+	}
+	return strings.ContainsAny(strings.ToLower(code.String()), "yd")
+}
+
+// excelSerialDate turns a spreadsheet date serial into YYYY-MM-DD, with the
+// time when the serial has one.
+func excelSerialDate(serial float64, use1904 bool) (string, bool) {
+	date, err := excelize.ExcelDateToTime(serial, use1904)
+	if err != nil {
+		return "", false
+	}
+	if date.Equal(date.Truncate(24 * time.Hour)) {
+		return date.Format("2006-01-02"), true
+	}
+	return date.Format("2006-01-02 15:04:05"), true
+}
+
+// plainNumber rewrites a number stored in scientific notation, like 5.56296E+12,
+// with all its digits. Other values are returned unchanged so text cells keep
+// their leading zeros.
+func plainNumber(value string) string {
+	if !strings.ContainsAny(value, "eE") {
+		return value
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreCPFZeros(t *testing.T) {
+	tests := []struct {
+		cpf  string
+		want string
+	}{
+		{"12345678909", "12345678909"},
+		{"1234567890", "01234567890"},
+		{"123456789", "00123456789"},
+		{"12345678", "12345678"},
+		{"123.456.789-09", "123.456.789-09"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, restoreCPFZeros(tt.cpf), tt.cpf)
+	}
+}
+
+func TestPlainNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"5.5629612345E+12", "5562961234500"},
+		{"1.1987654321e10", "11987654321"},
+		{"11987654321", "11987654321"},
+		{"00123", "00123"},
+		{"12.5", "12.5"},
+		{"email@example.com", "email@example.com"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, plainNumber(tt.value), tt.value)
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     int
+		format string
+		want   bool
+	}{
+		{"built-in date", 14, "", true},
+		{"built-in mmm-yy", 17, "", true},
+		{"built-in date time", 22, "", true},
+		{"built-in general", 0, "", false},
+		{"built-in number", 2, "", false},
+		{"built-in time only", 20, "", false},
+		{"custom iso date", 164, "yyyy-mm-dd", true},
+		{"custom year", 164, "yyyy", true},
+		{"custom portuguese date", 164, `dd "de" mmmm "de" yyyy`, true},
+		{"custom with locale", 164, "[$-416]dd/mm/yyyy", true},
+		{"custom number with quoted text", 164, `0 "dias"`, false},
+		{"custom number with escaped text", 164, `0\d`, false},
+		{"custom phone mask", 164, "(00) 00000-0000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isDateFormat(tt.id, tt.format))
+		})
+	}
+}
+
+func TestExcelSerialDate(t *testing.T) {
+	date, ok := excelSerialDate(32905, false)
+	require.True(t, ok)
+	assert.Equal(t, "1990-02-01", date)
+
+	date, ok = excelSerialDate(32905.4375, false)
+	require.True(t, ok)
+	assert.Equal(t, "1990-02-01 10:30:00", date)
+
+	date, ok = excelSerialDate(31443, true)
+	require.True(t, ok)
+	assert.Equal(t, "1990-02-01", date)
+}
+
+func TestResolveSheet(t *testing.T) {
+	names := []string{"Resumo", "Leads", "2"}
+
+	tests := []struct {
+		sheet string
+		want  int
+	}{
+		{"", 0},
+		{"leads", 1},
+		{" LEADS ", 1},
+		{"1", 0},
+		// A sheet named like a position wins over the position
+		{"2", 2},
+		{"3", 2},
+	}
+	for _, tt := range tests {
+		index, err := resolveSheet(names, tt.sheet)
+		require.NoError(t, err, tt.sheet)
+		assert.Equal(t, tt.want, index, tt.sheet)
+	}
+
+	_, err := resolveSheet(names, "Contatos")
+	assert.EqualError(t, err, "sheet 'Contatos' not found (available: Resumo, Leads, 2)")
+	_, err = resolveSheet(names, "4")
+	assert.Error(t, err)
+	_, err = resolveSheet(nil, "")
+	assert.EqualError(t, err, "file has no sheets")
+}
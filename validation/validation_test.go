@@ -0,0 +1,19 @@
+package validation
+
+import (
+	"bytes"
+	"mime/multipart"
+)
+
+// memFile is an in-memory upload
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error {
+	return nil
+}
+
+func newMemFile(content []byte) multipart.File {
+	return memFile{bytes.NewReader(content)}
+}
@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime/multipart"
+
+	"github.com/shakinm/xlsReader/cfb"
+	"github.com/shakinm/xlsReader/xls"
+	"github.com/shakinm/xlsReader/xls/record"
+	"github.com/shakinm/xlsReader/xls/structure"
+)
+
+// parseXLS reads the rows of the selected sheet of a legacy Excel 97-2003 file.
+// Numbers keep all their digits and date cells become YYYY-MM-DD, like parseExcel.
+func parseXLS(file multipart.File, opts ParseOptions) ([][]string, error) {
+	if err := checkXLS(file); err != nil {
+		return nil, err
+	}
+
+	workbook, err := xls.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xls file: %w", err)
+	}
+
+	sheets := workbook.GetSheets()
+	names := make([]string, len(sheets))
+	for i := range sheets {
+		names[i] = sheets[i].GetName()
+	}
+	index, err := resolveSheet(names, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	sheet := &sheets[index]
+
+	var rows [][]string
+	for _, r := range sheet.GetRows() {
+		cols := r.GetCols()
+		row := make([]string, len(cols))
+		for i, cell := range cols {
+			row[i] = xlsCellValue(&workbook, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// BIFF record types checkXLS looks for
+const (
+	xlsRecordBOF      = 0x0809
+	xlsRecordFilePass = 0x002F
+)
+
+// checkXLS rejects password-protected and macro-enabled files, then rewinds the
+// file. Encrypted xlsx files are stored in the same container as xls ones.
+func checkXLS(file multipart.File) error {
+	container, err := cfb.OpenReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open xls file: %w", err)
+	}
+
+	var book, root *cfb.Directory
+	for _, dir := range container.GetDirs() {
+		switch dir.Name() {
+		case "EncryptionInfo", "EncryptedPackage":
+			return ErrEncryptedWorkbook
+		case "_VBA_PROJECT_CUR":
+			return ErrMacroWorkbook
+		case "Workbook", "Book":
+			if book == nil {
+				book = dir
+			}
+		case "Root Entry":
+			root = dir
+		}
+	}
+
+	// A FILEPASS record right after the BOF of the workbook stream marks an encrypted file
+	if book != nil && root != nil {
+		stream, err := container.OpenObject(book, root)
+		if err != nil {
+			return fmt.Errorf("failed to open xls file: %w", err)
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(stream, header[:]); err == nil && binary.LittleEndian.Uint16(header[:2]) == xlsRecordBOF {
+			stream.Seek(int64(binary.LittleEndian.Uint16(header[2:])), io.SeekCurrent)
+			if _, err := io.ReadFull(stream, header[:]); err == nil && binary.LittleEndian.Uint16(header[:2]) == xlsRecordFilePass {
+				return ErrEncryptedWorkbook
+			}
+		}
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	return err
+}
+
+func xlsCellValue(workbook *xls.Workbook, cell structure.CellData) string {
+	switch cell.(type) {
+	case *record.Number, *record.Rk:
+	default:
+		return cell.GetString()
+	}
+
+	xf := workbook.GetXFbyIndex(cell.GetXFIndex())
+	formatID := xf.GetFormatIndex()
+	format := ""
+	if formatID >= 164 {
+		custom := workbook.GetFormatByIndex(formatID)
+		format = custom.String()
+	}
+	if isDateFormat(formatID, format) {
+		if date, ok := excelSerialDate(cell.GetFloat64(), false); ok {
+			return date
+		}
+	}
+	return cell.GetString()
+}
@@ -0,0 +1,272 @@
+package validation
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// parseExcel streams the rows of the selected sheet of an xlsx file. Numeric cells
+// are read from their raw value so phones and CPFs keep all their digits, and
+// cells with a date number format become YYYY-MM-DD.
+func parseExcel(file multipart.File, opts ParseOptions) (RowSource, error) {
+	archive, err := openArchive(file, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := excelize.OpenReader(file, excelize.Options{
+		UnzipSizeLimit:    opts.maxUnzipSize(),
+		UnzipXMLSizeLimit: opts.maxUnzipXMLSize(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open excel file: %w", err)
+	}
+
+	names := f.GetSheetList()
+	index, err := resolveSheet(names, opts.Sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	rows := &xlsxRows{file: f, dateStyles: make(map[int]bool)}
+	if props, err := f.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		rows.use1904 = *props.Date1904
+	}
+	if rows.raw, err = f.Rows(names[index]); err == nil {
+		rows.cells, err = openXLSXCells(archive, index)
+	}
+	if err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to read excel rows: %w", err)
+	}
+	return rows, nil
+}
+
+// xlsxRows reads a sheet twice in step, without loading it: excelize yields the
+// raw values, which keep the digits of numbers, and xlsxCells the cell styles,
+// which tell which numbers are dates.
+type xlsxRows struct {
+	file    *excelize.File
+	raw     *excelize.Rows
+	cells   *xlsxCells
+	rowNum  int
+	use1904 bool
+
+	// dateStyles caches whether a style has a date number format, most cells
+	// share a few styles
+	dateStyles map[int]bool
+}
+
+func (r *xlsxRows) Next() ([]string, error) {
+	if !r.raw.Next() {
+		if err := r.raw.Error(); err != nil {
+			return nil, fmt.Errorf("failed to read excel rows: %w", err)
+		}
+		return nil, io.EOF
+	}
+	r.rowNum++ // excelize yields the rows missing from the sheet as empty ones
+	row, err := r.raw.Columns(excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read excel rows: %w", err)
+	}
+	cells, err := r.cells.row(r.rowNum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read excel rows: %w", err)
+	}
+
+	for c, value := range row {
+		serial, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		cell, ok := cells[c+1]
+		// Text cells that look like numbers are kept as they are
+		if ok && !cell.numeric {
+			continue
+		}
+		if ok && r.isDateStyle(cell.style) {
+			if date, ok := excelSerialDate(serial, r.use1904); ok {
+				row[c] = date
+			}
+			continue
+		}
+		row[c] = plainNumber(value)
+	}
+	return row, nil
+}
+
+func (r *xlsxRows) isDateStyle(styleID int) bool {
+	isDate, ok := r.dateStyles[styleID]
+	if !ok {
+		if style, err := r.file.GetStyle(styleID); err == nil {
+			format := ""
+			if style.CustomNumFmt != nil {
+				format = *style.CustomNumFmt
+			}
+			isDate = isDateFormat(style.NumFmt, format)
+		}
+		r.dateStyles[styleID] = isDate
+	}
+	return isDate
+}
+
+func (r *xlsxRows) Close() error {
+	if r.raw != nil {
+		r.raw.Close()
+	}
+	if r.cells != nil {
+		r.cells.Close()
+	}
+	return r.file.Close()
+}
+
+// xlsxCell is what the excelize row iterator does not tell about a cell
+type xlsxCell struct {
+	style   int
+	numeric bool
+}
+
+// xlsxCells streams the style and type of the cells of a worksheet part
+type xlsxCells struct {
+	part    io.ReadCloser
+	decoder *xml.Decoder
+	rowNum  int              // number of the row in cells, 0 before the first
+	cells   map[int]xlsxCell // by 1-based column
+	done    bool
+}
+
+// openXLSXCells opens the worksheet part of the sheet-th sheet of the workbook
+func openXLSXCells(archive *zip.Reader, sheet int) (*xlsxCells, error) {
+	var workbook struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeZipXML(archive, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := decodeZipXML(archive, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	if sheet >= len(workbook.Sheets) {
+		return nil, fmt.Errorf("sheet %d not found", sheet+1)
+	}
+
+	target := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[sheet].RelID {
+			target = rel.Target
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("sheet %d has no worksheet", sheet+1)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	part, err := archive.Open(target)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxCells{part: part, decoder: xml.NewDecoder(part)}, nil
+}
+
+func decodeZipXML(archive *zip.Reader, name string, v any) error {
+	part, err := archive.Open(name)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	return xml.NewDecoder(part).Decode(v)
+}
+
+// row returns the cells of row rowNum, nil if the sheet has none. Rows must be
+// asked for in order.
+func (s *xlsxCells) row(rowNum int) (map[int]xlsxCell, error) {
+	for !s.done && s.rowNum < rowNum {
+		if err := s.readRow(); err != nil {
+			return nil, err
+		}
+	}
+	if s.rowNum != rowNum {
+		return nil, nil
+	}
+	return s.cells, nil
+}
+
+// readRow reads up to the end of the next row element
+func (s *xlsxCells) readRow() error {
+	column := 0
+	for {
+		token, err := s.decoder.Token()
+		if err == io.EOF {
+			s.done = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				s.rowNum++
+				if n, err := strconv.Atoi(xmlAttr(t, "r")); err == nil {
+					s.rowNum = n
+				}
+				s.cells = make(map[int]xlsxCell)
+				column = 0
+			case "c":
+				column++
+				if ref := xmlAttr(t, "r"); ref != "" {
+					if col, _, err := excelize.CellNameToCoordinates(ref); err == nil {
+						column = col
+					}
+				}
+				style, _ := strconv.Atoi(xmlAttr(t, "s"))
+				cellType := xmlAttr(t, "t")
+				s.cells[column] = xlsxCell{style: style, numeric: cellType == "" || cellType == "n"}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "row":
+				return nil
+			case "sheetData":
+				s.done = true
+				return nil
+			}
+		}
+	}
+}
+
+func (s *xlsxCells) Close() error {
+	return s.part.Close()
+}
+
+func xmlAttr(t xml.StartElement, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxFile saves an excelize workbook built by build
+func xlsxFile(t *testing.T, build func(f *excelize.File)) []byte {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	build(f)
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+	return buf.Bytes()
+}
+
+func readRows(t *testing.T, source RowSource) [][]string {
+	t.Helper()
+	defer source.Close()
+	var rows [][]string
+	for {
+		row, err := source.Next()
+		if err == io.EOF {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestParseExcelCellValues(t *testing.T) {
+	birth := time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC)
+	content := xlsxFile(t, func(f *excelize.File) {
+		f.SetSheetRow("Sheet1", "A1", &[]any{"phone", "cpf", "code", "iso", "mmm-yy", "year", "pt-BR", "amount"})
+		f.SetSheetRow("Sheet1", "A2", &[]any{11987654321, 1234567890, "00123", birth, birth, birth, birth, 12.5})
+
+		style := func(s excelize.Style) int {
+			id, err := f.NewStyle(&s)
+			require.NoError(t, err)
+			return id
+		}
+		iso, year, ptBR := "yyyy-mm-dd", "yyyy", `dd "de" mmmm "de" yyyy`
+		f.SetCellStyle("Sheet1", "D2", "D2", style(excelize.Style{CustomNumFmt: &iso}))
+		f.SetCellStyle("Sheet1", "E2", "E2", style(excelize.Style{NumFmt: 17}))
+		f.SetCellStyle("Sheet1", "F2", "F2", style(excelize.Style{CustomNumFmt: &year}))
+		f.SetCellStyle("Sheet1", "G2", "G2", style(excelize.Style{CustomNumFmt: &ptBR}))
+		f.SetCellStyle("Sheet1", "H2", "H2", style(excelize.Style{NumFmt: 2}))
+	})
+
+	source, err := parseExcel(newMemFile(content), ParseOptions{})
+	require.NoError(t, err)
+	rows := readRows(t, source)
+
+	require.Len(t, rows, 2)
+	assert.Equal(t, []string{"11987654321", "1234567890", "00123", "1990-02-01", "1990-02-01", "1990-02-01", "1990-02-01", "12.5"}, rows[1])
+}
+
+func TestParseExcelSheet(t *testing.T) {
+	content := xlsxFile(t, func(f *excelize.File) {
+		f.SetSheetRow("Sheet1", "A1", &[]any{"other"})
+		f.NewSheet("Leads")
+		f.SetSheetRow("Leads", "A1", &[]any{"name", "phone", "cpf"})
+		// Row numbers match the sheet, blank rows are skipped
+		f.SetSheetRow("Leads", "A4", &[]any{"Ana", 11987654321, 1234567890})
+	})
+
+	result, err := ParseFile(newMemFile(content), "leads.xlsx", ParseOptions{Sheet: "leads"})
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, 4, result.Rows[0].RowNumber)
+	assert.Equal(t, "11987654321", result.Rows[0].Phone)
+	// The leading zero dropped by the spreadsheet is restored
+	assert.Equal(t, "01234567890", result.Rows[0].CPF)
+
+	_, err = ParseFile(newMemFile(content), "leads.xlsx", ParseOptions{Sheet: "Contatos"})
+	assert.EqualError(t, err, "failed to parse file: sheet 'Contatos' not found (available: Sheet1, Leads)")
+}
+
+func TestParserMaxRows(t *testing.T) {
+	content := xlsxFile(t, func(f *excelize.File) {
+		f.SetSheetRow("Sheet1", "A1", &[]any{"name", "phone"})
+		for i := 2; i <= 5; i++ {
+			cell, _ := excelize.CoordinatesToCellName(1, i)
+			f.SetSheetRow("Sheet1", cell, &[]any{"Ana", "11987654321"})
+		}
+	})
+
+	_, err := ParseFile(newMemFile(content), "leads.xlsx", ParseOptions{MaxRows: 4})
+	assert.NoError(t, err)
+	_, err = ParseFile(newMemFile(content), "leads.xlsx", ParseOptions{MaxRows: 3})
+	assert.EqualError(t, err, "file must have at most 3 data rows")
+}